@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// titleLengthBuckets are the inclusive upper bounds of the title length histogram;
+// longer titles fall into a final open-ended bucket
+var titleLengthBuckets = []int{10, 25, 50, 100}
+
+// titleLengthBucket is one histogram bar in the title stats response
+type titleLengthBucket struct {
+	Range string `json:"range"`
+	Count int    `json:"count"`
+}
+
+// titleStats is the GET /admin/title-stats response body
+type titleStats struct {
+	Count     int                 `json:"count"`
+	Min       int                 `json:"min"`
+	Max       int                 `json:"max"`
+	Average   float64             `json:"average"`
+	Histogram []titleLengthBucket `json:"histogram"`
+}
+
+// requireAdmin rejects requests that do not carry the configured admin bearer token.
+// Admin routes answer 404 when no token is configured.
+func requireAdmin(c *gin.Context) {
+	if config.AdminToken == "" {
+		respondError(c, http.StatusNotFound, "not found")
+		return
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminToken)) != 1 {
+		respondError(c, http.StatusUnauthorized, "admin token required")
+		return
+	}
+	c.Next()
+}
+
+// getTitleStats handles GET /admin/title-stats
+func getTitleStats(c *gin.Context) {
+	lengths, err := db.TitleLengths(c.Request.Context())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, computeTitleStats(lengths))
+}
+
+// computeTitleStats summarizes title lengths into min, max, average and a histogram
+func computeTitleStats(lengths []int) titleStats {
+	stats := titleStats{Count: len(lengths)}
+	lower := 0
+	for _, upper := range titleLengthBuckets {
+		stats.Histogram = append(stats.Histogram, titleLengthBucket{Range: strconv.Itoa(lower) + "-" + strconv.Itoa(upper)})
+		lower = upper + 1
+	}
+	stats.Histogram = append(stats.Histogram, titleLengthBucket{Range: strconv.Itoa(lower) + "+"})
+	if len(lengths) == 0 {
+		return stats
+	}
+
+	stats.Min, stats.Max = lengths[0], lengths[0]
+	sum := 0
+	for _, n := range lengths {
+		stats.Min = min(stats.Min, n)
+		stats.Max = max(stats.Max, n)
+		sum += n
+
+		bucket := len(titleLengthBuckets)
+		for i, upper := range titleLengthBuckets {
+			if n <= upper {
+				bucket = i
+				break
+			}
+		}
+		stats.Histogram[bucket].Count++
+	}
+	stats.Average = float64(sum) / float64(len(lengths))
+	return stats
+}
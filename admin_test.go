@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTitleStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Plan the quarterly offsite for the whole team"})
+		config.AdminToken = "secret"
+		defer func() { config = defaultConfig() }()
+
+		req, _ := http.NewRequest("GET", "/admin/title-stats", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response titleStats
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 3, response.Count)
+		assert.Equal(t, 8, response.Min)
+		assert.Equal(t, 45, response.Max)
+		assert.InDelta(t, 21.67, response.Average, 0.01)
+		assert.Equal(t, []titleLengthBucket{
+			{Range: "0-10", Count: 1},
+			{Range: "11-25", Count: 1},
+			{Range: "26-50", Count: 1},
+			{Range: "51-100", Count: 0},
+			{Range: "101+", Count: 0},
+		}, response.Histogram)
+	})
+
+	t.Run("Empty Database", func(t *testing.T) {
+		db = NewDatabase()
+		config.AdminToken = "secret"
+		defer func() { config = defaultConfig() }()
+
+		req, _ := http.NewRequest("GET", "/admin/title-stats", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response titleStats
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 0, response.Count)
+		assert.Equal(t, 5, len(response.Histogram))
+	})
+
+	t.Run("Wrong Token", func(t *testing.T) {
+		resetTodos()
+		config.AdminToken = "secret"
+		defer func() { config = defaultConfig() }()
+
+		req, _ := http.NewRequest("GET", "/admin/title-stats", nil)
+		req.Header.Set("Authorization", "Bearer guess")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Admin Disabled", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/admin/title-stats", nil)
+		req.Header.Set("Authorization", "Bearer ")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
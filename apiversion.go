@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API versions served by SetupRouter. Unversioned routes serve v1.
+const (
+	apiV1 = 1
+	apiV2 = 2
+)
+
+// apiVersionKey is the gin context key holding the API version of the route
+const apiVersionKey = "api_version"
+
+// withAPIVersion records version as the API version of every request it handles
+func withAPIVersion(version int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiVersionKey, version)
+		c.Next()
+	}
+}
+
+// apiVersion returns the API version of the request's route, v1 if none was recorded
+func apiVersion(c *gin.Context) int {
+	if version := c.GetInt(apiVersionKey); version != 0 {
+		return version
+	}
+	return apiV1
+}
+
+// todoV1 is the frozen v1 shape of a todo. Todo is the v2 shape and may grow;
+// todoV1 only gains fields a client has to ask for, like is_overdue, so v1
+// clients keep receiving exactly the fields they always have.
+type todoV1 struct {
+	XMLName        xml.Name   `json:"-" xml:"todo"`
+	ID             int        `json:"id" xml:"id"`
+	Title          string     `json:"title" xml:"title"`
+	Done           bool       `json:"done" xml:"done"`
+	UserID         string     `json:"user_id,omitempty" xml:"user_id,omitempty"`
+	Version        int        `json:"version" xml:"version"`
+	CreatedAt      time.Time  `json:"created_at" xml:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" xml:"updated_at"`
+	DueDate        *time.Time `json:"due_date,omitempty" xml:"due_date,omitempty"`
+	ClaimedBy      string     `json:"claimed_by,omitempty" xml:"claimed_by,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty" xml:"lease_expires_at,omitempty"`
+	CategoryID     *int       `json:"category_id,omitempty" xml:"category_id,omitempty"`
+	Tags           []string   `json:"tags,omitempty" xml:"tags>tag,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+	Recurrence     string     `json:"recurrence,omitempty" xml:"recurrence,omitempty"`
+	ParentID       *int       `json:"parent_id,omitempty" xml:"parent_id,omitempty"`
+	Position       int        `json:"position" xml:"position"`
+	IsOverdue      *bool      `json:"is_overdue,omitempty" xml:"is_overdue,omitempty"`
+}
+
+// MarshalJSON encodes the todo, writing done as a string when config.DoneAsString is set
+func (t todoV1) MarshalJSON() ([]byte, error) {
+	type plain todoV1
+	if !config.DoneAsString {
+		return json.Marshal(plain(t))
+	}
+	return json.Marshal(struct {
+		plain
+		Done string `json:"done"`
+	}{plain(t), strconv.FormatBool(t.Done)})
+}
+
+// toTodoV1 maps a todo to the v1 shape
+func toTodoV1(t Todo) todoV1 {
+	return todoV1{
+		ID:             t.ID,
+		Title:          t.Title,
+		Done:           t.Done,
+		UserID:         t.UserID,
+		Version:        t.Version,
+		CreatedAt:      t.CreatedAt,
+		UpdatedAt:      t.UpdatedAt,
+		DueDate:        t.DueDate,
+		ClaimedBy:      t.ClaimedBy,
+		LeaseExpiresAt: t.LeaseExpiresAt,
+		CategoryID:     t.CategoryID,
+		Tags:           t.Tags,
+		DeletedAt:      t.DeletedAt,
+		Recurrence:     t.Recurrence,
+		ParentID:       t.ParentID,
+		Position:       t.Position,
+		IsOverdue:      t.IsOverdue,
+	}
+}
+
+// toTodosV1 maps a list of todos to the v1 shape, keeping an empty list non-nil
+func toTodosV1(todos []Todo) []todoV1 {
+	v1 := make([]todoV1, 0, len(todos))
+	for _, todo := range todos {
+		v1 = append(v1, toTodoV1(todo))
+	}
+	return v1
+}
+
+// todoXMLListV1 is todoXMLList in the v1 shape
+type todoXMLListV1 struct {
+	XMLName xml.Name `xml:"todos"`
+	Todos   []todoV1 `xml:"todo"`
+}
+
+// todoPageV1 is todoPage in the v1 shape
+type todoPageV1 struct {
+	XMLName    xml.Name `json:"-" xml:"todo_page"`
+	Todos      []todoV1 `json:"todos" xml:"todos>todo"`
+	NextCursor *string  `json:"next_cursor" xml:"next_cursor,omitempty"`
+}
+
+// todoListV1 is todoList in the v1 shape
+type todoListV1 struct {
+	XMLName xml.Name `json:"-" xml:"todo_list"`
+	Data    []todoV1 `json:"data" xml:"data>todo"`
+	Total   int      `json:"total" xml:"total"`
+	Limit   int      `json:"limit" xml:"limit"`
+	Offset  int      `json:"offset" xml:"offset"`
+}
+
+// todoEventV1 is todoEvent in the v1 shape
+type todoEventV1 struct {
+	Type string  `json:"type"`
+	ID   int     `json:"id,omitempty"`
+	Todo *todoV1 `json:"todo,omitempty"`
+}
+
+// forAPIVersion returns body as the given API version renders it. v2 renders
+// bodies as they are; v1 maps the todos in them to todoV1. Bodies holding no
+// todos are the same in every version.
+func forAPIVersion(version int, body any) any {
+	if version != apiV1 {
+		return body
+	}
+	switch b := body.(type) {
+	case Todo:
+		return toTodoV1(b)
+	case *Todo:
+		return toTodoV1(*b)
+	case []Todo:
+		return toTodosV1(b)
+	case todoXMLList:
+		return todoXMLListV1{Todos: toTodosV1(b.Todos)}
+	case todoPage:
+		return todoPageV1{Todos: toTodosV1(b.Todos), NextCursor: b.NextCursor}
+	case todoList:
+		return todoListV1{Data: toTodosV1(b.Data), Total: b.Total, Limit: b.Limit, Offset: b.Offset}
+	case todoEvent:
+		event := todoEventV1{Type: b.Type, ID: b.ID}
+		if b.Todo != nil {
+			todo := toTodoV1(*b.Todo)
+			event.Todo = &todo
+		}
+		return event
+	}
+	return body
+}
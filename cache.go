@@ -0,0 +1,196 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// todoCacheKey identifies a cached todo. IDs are looked up per user, so the
+// user is part of the key.
+type todoCacheKey struct {
+	user string
+	id   int
+}
+
+// todoCacheEntry is a cached GetTodoByID result
+type todoCacheEntry struct {
+	key     todoCacheKey
+	todo    Todo
+	expires time.Time
+}
+
+// cachingDatabase wraps a DatabaseInterface and keeps up to size recent
+// GetTodoByID results for ttl, evicting the least recently used first. Writes
+// naming a single todo drop its entry; writes that may touch many todos drop
+// them all. Every other call goes straight to the wrapped store.
+type cachingDatabase struct {
+	DatabaseInterface
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[todoCacheKey]*list.Element
+	// recent holds the entries, most recently used first
+	recent *list.List
+	// generation moves on every invalidation, so a read that raced with a
+	// write does not cache what it saw before the write
+	generation uint64
+}
+
+// newCachingDatabase returns db wrapped with a GetTodoByID cache of size entries
+func newCachingDatabase(db DatabaseInterface, size int, ttl time.Duration) *cachingDatabase {
+	return &cachingDatabase{
+		DatabaseInterface: db,
+		size:              size,
+		ttl:               ttl,
+		entries:           map[todoCacheKey]*list.Element{},
+		recent:            list.New(),
+	}
+}
+
+// GetTodoByID returns the cached todo if there is a fresh entry for it, and
+// otherwise reads it from the wrapped store and caches it
+func (d *cachingDatabase) GetTodoByID(ctx context.Context, id int) (*Todo, error) {
+	key := todoCacheKey{user: userIDFromContext(ctx), id: id}
+	now := timeNow()
+
+	d.mu.Lock()
+	if elem, ok := d.entries[key]; ok {
+		entry := elem.Value.(*todoCacheEntry)
+		if now.Before(entry.expires) {
+			d.recent.MoveToFront(elem)
+			todo := entry.todo
+			d.mu.Unlock()
+			return &todo, nil
+		}
+		d.remove(elem)
+	}
+	generation := d.generation
+	d.mu.Unlock()
+
+	todo, err := d.DatabaseInterface.GetTodoByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.generation == generation {
+		d.add(&todoCacheEntry{key: key, todo: *todo, expires: now.Add(d.ttl)})
+	}
+	return todo, nil
+}
+
+func (d *cachingDatabase) UpdateTodo(ctx context.Context, id, version int, todo Todo) (*Todo, *Todo, error) {
+	defer d.invalidate(ctx, id)
+	return d.DatabaseInterface.UpdateTodo(ctx, id, version, todo)
+}
+
+func (d *cachingDatabase) PatchTodo(ctx context.Context, id int, fields TodoPatch) (*Todo, *Todo, error) {
+	defer d.invalidate(ctx, id)
+	return d.DatabaseInterface.PatchTodo(ctx, id, fields)
+}
+
+func (d *cachingDatabase) DeleteTodo(ctx context.Context, id int) error {
+	defer d.invalidate(ctx, id)
+	return d.DatabaseInterface.DeleteTodo(ctx, id)
+}
+
+func (d *cachingDatabase) HardDeleteTodo(ctx context.Context, id int) error {
+	defer d.invalidate(ctx, id)
+	return d.DatabaseInterface.HardDeleteTodo(ctx, id)
+}
+
+func (d *cachingDatabase) RestoreTodo(ctx context.Context, id int) error {
+	defer d.invalidate(ctx, id)
+	return d.DatabaseInterface.RestoreTodo(ctx, id)
+}
+
+func (d *cachingDatabase) DeleteTodos(ctx context.Context, ids []int) (int, error) {
+	defer d.invalidate(ctx, ids...)
+	return d.DatabaseInterface.DeleteTodos(ctx, ids)
+}
+
+func (d *cachingDatabase) DeleteTodoCascade(ctx context.Context, id int, hard bool) (int, error) {
+	defer d.invalidateAll()
+	return d.DatabaseInterface.DeleteTodoCascade(ctx, id, hard)
+}
+
+func (d *cachingDatabase) UpdateTodosWhere(ctx context.Context, filter TodoFilter, set TodoPatch) (int, error) {
+	defer d.invalidateAll()
+	return d.DatabaseInterface.UpdateTodosWhere(ctx, filter, set)
+}
+
+func (d *cachingDatabase) SetAllDone(ctx context.Context, done bool) (int, error) {
+	defer d.invalidateAll()
+	return d.DatabaseInterface.SetAllDone(ctx, done)
+}
+
+func (d *cachingDatabase) ReorderTodos(ctx context.Context, ids []int) error {
+	defer d.invalidate(ctx, ids...)
+	return d.DatabaseInterface.ReorderTodos(ctx, ids)
+}
+
+func (d *cachingDatabase) ClaimTodos(ctx context.Context, workerID string, n int, lease time.Duration) ([]Todo, error) {
+	defer d.invalidateAll()
+	return d.DatabaseInterface.ClaimTodos(ctx, workerID, n, lease)
+}
+
+func (d *cachingDatabase) ClearCompleted(ctx context.Context) (int, error) {
+	defer d.invalidateAll()
+	return d.DatabaseInterface.ClearCompleted(ctx)
+}
+
+func (d *cachingDatabase) DeleteCategory(ctx context.Context, id int) error {
+	defer d.invalidateAll()
+	return d.DatabaseInterface.DeleteCategory(ctx, id)
+}
+
+func (d *cachingDatabase) WithTx(ctx context.Context, fn func(tx TxInterface) error) error {
+	defer d.invalidateAll()
+	return d.DatabaseInterface.WithTx(ctx, fn)
+}
+
+// invalidate drops the cached todos of the user in ctx with the given IDs
+func (d *cachingDatabase) invalidate(ctx context.Context, ids ...int) {
+	user := userIDFromContext(ctx)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.generation++
+	for _, id := range ids {
+		if elem, ok := d.entries[todoCacheKey{user: user, id: id}]; ok {
+			d.remove(elem)
+		}
+	}
+}
+
+// invalidateAll empties the cache
+func (d *cachingDatabase) invalidateAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.generation++
+	clear(d.entries)
+	d.recent.Init()
+}
+
+// add caches entry, evicting the least recently used entry when full.
+// Callers must hold mu.
+func (d *cachingDatabase) add(entry *todoCacheEntry) {
+	if elem, ok := d.entries[entry.key]; ok {
+		d.remove(elem)
+	}
+	d.entries[entry.key] = d.recent.PushFront(entry)
+	if d.recent.Len() > d.size {
+		d.remove(d.recent.Back())
+	}
+}
+
+// remove drops a cached entry. Callers must hold mu.
+func (d *cachingDatabase) remove(elem *list.Element) {
+	delete(d.entries, elem.Value.(*todoCacheEntry).key)
+	d.recent.Remove(elem)
+}
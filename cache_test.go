@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingDatabase is a database that counts lookups by ID
+type countingDatabase struct {
+	DatabaseInterface
+	lookups int
+}
+
+func (d *countingDatabase) GetTodoByID(ctx context.Context, id int) (*Todo, error) {
+	d.lookups++
+	return d.DatabaseInterface.GetTodoByID(ctx, id)
+}
+
+func TestCachingDatabase(t *testing.T) {
+	ctx := context.Background()
+	newCache := func(size int) (*cachingDatabase, *countingDatabase) {
+		store := &countingDatabase{DatabaseInterface: NewDatabase(
+			Todo{ID: 1, Title: "Learn Go"},
+			Todo{ID: 2, Title: "Set up CI/CD"},
+			Todo{ID: 3, Title: "Write docs"},
+		)}
+		return newCachingDatabase(store, size, time.Minute), store
+	}
+
+	t.Run("Serves Repeated Reads From Cache", func(t *testing.T) {
+		cache, store := newCache(10)
+		cache.GetTodoByID(ctx, 1)
+		todo, err := cache.GetTodoByID(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Learn Go", todo.Title)
+		assert.Equal(t, 1, store.lookups)
+	})
+
+	t.Run("Update Invalidates", func(t *testing.T) {
+		cache, _ := newCache(10)
+		cache.GetTodoByID(ctx, 1)
+		title := "Learn Go properly"
+		cache.PatchTodo(ctx, 1, TodoPatch{Title: &title})
+		todo, _ := cache.GetTodoByID(ctx, 1)
+		assert.Equal(t, title, todo.Title)
+
+		cache.UpdateTodo(ctx, 1, 0, Todo{Title: "Master Go", Done: true})
+		todo, _ = cache.GetTodoByID(ctx, 1)
+		assert.Equal(t, "Master Go", todo.Title)
+		assert.True(t, todo.Done)
+	})
+
+	t.Run("Delete Invalidates", func(t *testing.T) {
+		cache, _ := newCache(10)
+		cache.GetTodoByID(ctx, 1)
+		cache.DeleteTodo(ctx, 1)
+
+		_, err := cache.GetTodoByID(ctx, 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Bulk Write Invalidates", func(t *testing.T) {
+		cache, _ := newCache(10)
+		cache.GetTodoByID(ctx, 2)
+		done := true
+		cache.UpdateTodosWhere(ctx, TodoFilter{}, TodoPatch{Done: &done})
+
+		todo, _ := cache.GetTodoByID(ctx, 2)
+		assert.True(t, todo.Done)
+	})
+
+	t.Run("Entries Expire", func(t *testing.T) {
+		cache, store := newCache(10)
+		cache.GetTodoByID(ctx, 1)
+		timeNow = func() time.Time { return time.Now().Add(time.Hour) }
+		defer func() { timeNow = time.Now }()
+		cache.GetTodoByID(ctx, 1)
+
+		assert.Equal(t, 2, store.lookups)
+	})
+
+	t.Run("Evicts Least Recently Used", func(t *testing.T) {
+		cache, store := newCache(2)
+		cache.GetTodoByID(ctx, 1)
+		cache.GetTodoByID(ctx, 2)
+		cache.GetTodoByID(ctx, 1)
+		cache.GetTodoByID(ctx, 3)
+		assert.Equal(t, 3, store.lookups)
+
+		cache.GetTodoByID(ctx, 1)
+		assert.Equal(t, 3, store.lookups)
+		cache.GetTodoByID(ctx, 2)
+		assert.Equal(t, 4, store.lookups)
+	})
+
+	t.Run("Scoped To User", func(t *testing.T) {
+		cache, _ := newCache(10)
+		cache.GetTodoByID(ctx, 1)
+
+		_, err := cache.GetTodoByID(withUserID(ctx, "someone-else"), 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Category is a named list that todos can be grouped into
+type Category struct {
+	XMLName xml.Name `json:"-" xml:"category"`
+	ID      int      `json:"id" xml:"id"`
+	Name    string   `json:"name" xml:"name"`
+	// UserID is the owner of the category; only they can see, use or delete it
+	UserID string `json:"user_id,omitempty" xml:"user_id,omitempty"`
+}
+
+// createCategoryRequest is the POST /categories request body
+type createCategoryRequest struct {
+	Name string `json:"name" binding:"required,notblank,max=255"`
+}
+
+// getCategories handles GET /categories
+func getCategories(c *gin.Context) {
+	categories, err := db.GetCategories(c.Request.Context())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respondCategories(c, http.StatusOK, categories)
+}
+
+// postCategory handles POST /categories
+func postCategory(c *gin.Context) {
+	var body createCategoryRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	category := Category{Name: body.Name}
+	if err := db.CreateCategory(c.Request.Context(), &category); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respondNegotiated(c, http.StatusCreated, category)
+}
+
+// getCategoryTodos handles GET /categories/:id/todos
+func getCategoryTodos(c *gin.Context) {
+	id := c.Param("id")
+
+	todos, err := db.GetTodosByCategory(c.Request.Context(), toInt(id))
+	if errors.Is(err, ErrCategoryNotFound) {
+		respondError(c, http.StatusNotFound, "Category not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respondTodos(c, http.StatusOK, todos)
+}
+
+// deleteCategory handles DELETE /categories/:id. Todos in the category are
+// kept without a category.
+func deleteCategory(c *gin.Context) {
+	id := c.Param("id")
+
+	err := db.DeleteCategory(c.Request.Context(), toInt(id))
+	if errors.Is(err, ErrCategoryNotFound) {
+		respondError(c, http.StatusNotFound, "Category not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// resetCategories resets the todos and adds the named categories
+func resetCategories(names ...string) {
+	resetTodos()
+	for _, name := range names {
+		testDB.CreateCategory(context.Background(), &Category{Name: name})
+	}
+}
+
+func TestCategories(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Create And List", func(t *testing.T) {
+		resetCategories("Work")
+		req, _ := http.NewRequest("POST", "/categories", strings.NewReader(`{"name": "Home"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, `{"id":2,"name":"Home"}`, w.Body.String())
+
+		req, _ = http.NewRequest("GET", "/categories", nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `[{"id":1,"name":"Work"},{"id":2,"name":"Home"}]`, w.Body.String())
+	})
+
+	t.Run("Missing Name", func(t *testing.T) {
+		resetCategories()
+		req, _ := http.NewRequest("POST", "/categories", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, testDB.categories)
+	})
+
+	t.Run("Todos In Category", func(t *testing.T) {
+		resetCategories("Work")
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(`{"title": "Write report", "category_id": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		req, _ = http.NewRequest("GET", "/categories/1/todos", nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, []int{3}, todoIDs(response))
+		assert.Equal(t, 1, *response[0].CategoryID)
+	})
+
+	t.Run("Unknown Category On Create", func(t *testing.T) {
+		resetCategories()
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(`{"title": "Write report", "category_id": 7}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "category_id")
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+
+	t.Run("Move Between Categories", func(t *testing.T) {
+		resetCategories("Work", "Home")
+		send := func(method, payload string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(method, "/todos/1", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		assert.Equal(t, http.StatusOK, send("PATCH", `{"category_id": 1}`).Code)
+		assert.Equal(t, 1, *testDB.todos[0].CategoryID)
+
+		assert.Equal(t, http.StatusOK, send("PUT", `{"title": "Learn Go", "done": false, "category_id": 2}`).Code)
+		assert.Equal(t, 2, *testDB.todos[0].CategoryID)
+
+		w := send("PATCH", `{"category_id": 7}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "category_id")
+		assert.Equal(t, 2, *testDB.todos[0].CategoryID)
+
+		assert.Equal(t, http.StatusBadRequest, send("PUT", `{"title": "Learn Go", "done": false, "category_id": 7}`).Code)
+
+		assert.Equal(t, http.StatusOK, send("PATCH", `{"category_id": 0}`).Code)
+		assert.Nil(t, testDB.todos[0].CategoryID)
+	})
+
+	t.Run("Unknown Category Todos", func(t *testing.T) {
+		resetCategories()
+		req, _ := http.NewRequest("GET", "/categories/7/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Delete Keeps Todos", func(t *testing.T) {
+		resetCategories("Work")
+		category := 1
+		testDB.todos[0].CategoryID = &category
+		req, _ := http.NewRequest("DELETE", "/categories/1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, testDB.categories)
+		assert.Equal(t, []int{1, 2}, todoIDs(testDB.todos))
+		assert.Nil(t, testDB.todos[0].CategoryID)
+		assert.Equal(t, 2, testDB.todos[0].Version)
+		assert.Equal(t, 1, testDB.todos[1].Version)
+
+		req, _ = http.NewRequest("DELETE", "/categories/1", nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Delete Changes The ETag Of Its Todos", func(t *testing.T) {
+		resetCategories("Work")
+		category := 1
+		testDB.todos[0].CategoryID = &category
+		get := func(etag string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("GET", "/todos/1", nil)
+			req.Header.Set("If-None-Match", etag)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+		etag := get("").Header().Get("ETag")
+
+		req, _ := http.NewRequest("DELETE", "/categories/1", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, http.StatusOK, get(etag).Code)
+	})
+
+	t.Run("Scoped To User", func(t *testing.T) {
+		resetCategories("Work")
+		category := 1
+		testDB.todos[0].CategoryID = &category
+		send := func(method, path, body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(userIDHeader, "bob")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		w := send("GET", "/categories", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `[]`, w.Body.String())
+
+		assert.Equal(t, http.StatusNotFound, send("GET", "/categories/1/todos", "").Code)
+		assert.Equal(t, http.StatusBadRequest, send("POST", "/todos", `{"title": "Sneak in", "category_id": 1}`).Code)
+		assert.Equal(t, http.StatusNotFound, send("DELETE", "/categories/1", "").Code)
+		assert.Len(t, testDB.categories, 1)
+		assert.Equal(t, 1, *testDB.todos[0].CategoryID)
+
+		w = send("POST", "/categories", `{"name": "Bob's"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, `{"id":2,"name":"Bob's","user_id":"bob"}`, w.Body.String())
+	})
+}
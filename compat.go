@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// legacyTodo is the todo shape used by the legacy mobile app under /compat
+type legacyTodo struct {
+	ID        int    `json:"id"`
+	Task      string `json:"task"`
+	Completed bool   `json:"completed"`
+}
+
+// legacyTodoRequest is the POST and PUT /compat/todos request body. The
+// legacy app sends id back, so it is accepted but ignored.
+type legacyTodoRequest struct {
+	ID        int    `json:"id"`
+	Task      string `json:"task" binding:"required,notblank,max=255"`
+	Completed bool   `json:"completed"`
+}
+
+// toLegacyTodo maps a todo to the legacy shape
+func toLegacyTodo(todo Todo) legacyTodo {
+	return legacyTodo{ID: todo.ID, Task: todo.Title, Completed: todo.Done}
+}
+
+// getLegacyTodos handles GET /compat/todos
+func getLegacyTodos(c *gin.Context) {
+	limit, offset, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	page, err := db.GetTodos(c.Request.Context(), TodoFilter{}, TodoOrder{}, limit, offset)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	legacy := make([]legacyTodo, 0, len(page))
+	for _, todo := range page {
+		legacy = append(legacy, toLegacyTodo(todo))
+	}
+	c.JSON(http.StatusOK, legacy)
+}
+
+// postLegacyTodo handles POST /compat/todos
+func postLegacyTodo(c *gin.Context) {
+	var body legacyTodoRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	todo := Todo{Title: body.Task, Done: body.Completed}
+	err := db.CreateTodo(c.Request.Context(), &todo)
+	if errors.Is(err, ErrTodoExists) {
+		respondError(c, http.StatusConflict, "a todo with this title already exists")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	publishEvent(c, todoEvent{Type: eventCreated, ID: todo.ID, Todo: &todo})
+	c.JSON(http.StatusCreated, toLegacyTodo(todo))
+}
+
+// putLegacyTodo handles PUT /compat/todos/:id. Fields the legacy shape does
+// not know about, such as due_date, are left unchanged.
+func putLegacyTodo(c *gin.Context) {
+	id := c.Param("id")
+	var body legacyTodoRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	todo, next, err := db.PatchTodo(c.Request.Context(), toInt(id), TodoPatch{Title: &body.Task, Done: &body.Completed})
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Todo not found")
+		return
+	}
+	if errors.Is(err, ErrTodoExists) {
+		respondError(c, http.StatusConflict, "a todo with this title already exists")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	publishEvent(c, todoEvent{Type: eventUpdated, ID: todo.ID, Todo: todo})
+	if next != nil {
+		publishEvent(c, todoEvent{Type: eventCreated, ID: next.ID, Todo: next})
+	}
+	c.JSON(http.StatusOK, toLegacyTodo(*todo))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLegacyTodos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos()
+		testDB.todos[1].Done = true
+		req, _ := http.NewRequest("GET", "/compat/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 2, len(response))
+		assert.Equal(t, map[string]interface{}{"id": 2.0, "task": "Set up CI/CD", "completed": true}, response[1])
+	})
+
+	t.Run("Invalid Pagination", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/compat/todos?limit=-1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestPostLegacyTodo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos()
+		payload := `{"task": "Legacy task", "completed": true}`
+		req, _ := http.NewRequest("POST", "/compat/todos", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, `{"id":3,"task":"Legacy task","completed":true}`, w.Body.String())
+		assert.Equal(t, "Legacy task", testDB.todos[2].Title)
+		assert.Equal(t, true, testDB.todos[2].Done)
+	})
+
+	t.Run("Duplicate Task", func(t *testing.T) {
+		resetTodos()
+		config.UniqueTitles = true
+		defer func() { config = defaultConfig() }()
+
+		req, _ := http.NewRequest("POST", "/compat/todos", strings.NewReader(`{"task": "learn go"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+
+	t.Run("Invalid Task", func(t *testing.T) {
+		for _, payload := range []string{`{"task": ""}`, `{"task": "   "}`, `{"task": "` + strings.Repeat("a", 256) + `"}`, `{"completed": true}`} {
+			resetTodos()
+			req, _ := http.NewRequest("POST", "/compat/todos", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, payload)
+			assert.Contains(t, errorBody(w).Fields, "task", payload)
+			assert.Equal(t, 2, len(testDB.todos))
+		}
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/compat/todos", strings.NewReader(`{"task": }`))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestPutLegacyTodo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success Keeps Due Date", func(t *testing.T) {
+		due := time.Now()
+		resetTodos()
+		testDB.todos[0].DueDate = &due
+		payload := `{"task": "Renamed", "completed": true}`
+		req, _ := http.NewRequest("PUT", "/compat/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"id":1,"task":"Renamed","completed":true}`, w.Body.String())
+		assert.NotNil(t, testDB.todos[0].DueDate)
+	})
+
+	t.Run("Blank Task", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("PUT", "/compat/todos/1", strings.NewReader(`{"task": "   "}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "Learn Go", testDB.todos[0].Title)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		resetTodos()
+		payload := `{"task": "Renamed", "completed": true}`
+		req, _ := http.NewRequest("PUT", "/compat/todos/999", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PUT /todos/:id update modes
+const (
+	// putModeReplace requires every field in the body and overwrites the todo
+	putModeReplace = "replace"
+	// putModeMerge only changes the fields present in the body
+	putModeMerge = "merge"
+)
+
+// Behaviors when an unpaginated GET /todos exceeds ListMaxRows
+const (
+	// listOverflowReject answers 400 so the client has to narrow its request
+	listOverflowReject = "reject"
+	// listOverflowTruncate returns the first ListMaxRows todos with a Warning header
+	listOverflowTruncate = "truncate"
+)
+
+// Config holds the settings read from the environment at startup
+type Config struct {
+	// Addr is the listen address: ADDR if set, otherwise ":" + PORT, otherwise ":8080"
+	Addr string
+	// PutMode selects PUT semantics (PUT_MODE). The default, "replace",
+	// rejects bodies with omitted fields; "merge" keeps their stored values.
+	PutMode string
+	// ListMaxRows caps how many todos one GET /todos returns (LIST_MAX_ROWS,
+	// default and at most maxPageLimit). Asking for more is handled according
+	// to ListOverflow. 0 turns that off, and larger limits are then silently
+	// lowered to maxPageLimit.
+	ListMaxRows int
+	// ListOverflow is what happens past the cap (LIST_OVERFLOW): "truncate"
+	// (default) or "reject"
+	ListOverflow string
+	// DoneAsString renders done as "true"/"false" instead of a JSON boolean
+	// for legacy consumers (DONE_AS_STRING, default false)
+	DoneAsString bool
+	// AdminToken is the bearer token required on /admin routes (ADMIN_TOKEN).
+	// When empty the admin routes are disabled.
+	AdminToken string
+	// ShutdownTimeout is how long in-flight requests get to finish after
+	// SIGINT/SIGTERM (SHUTDOWN_TIMEOUT, a Go duration, default 10s)
+	ShutdownTimeout time.Duration
+	// LogLevel is the minimum level written by the JSON logger (LOG_LEVEL:
+	// debug, info, warn or error; default info)
+	LogLevel slog.Level
+	// CORSOrigins lists the origins allowed to make cross-origin requests
+	// (CORS_ORIGINS, comma-separated). Empty denies all cross-origin requests.
+	CORSOrigins []string
+	// RateLimitRPS is how many requests per second each client IP may make
+	// (RATE_LIMIT_RPS, default 0, which disables rate limiting)
+	RateLimitRPS float64
+	// RateLimitBurst is how many requests a client IP may make at once
+	// (RATE_LIMIT_BURST, default RATE_LIMIT_RPS rounded up)
+	RateLimitBurst int
+	// TrustedProxies lists the proxy IPs and CIDRs whose X-Forwarded-For and
+	// X-Real-IP headers are believed when working out the client IP
+	// (TRUSTED_PROXIES, comma-separated). Empty trusts no proxy, so the client
+	// IP is always the address the request came from.
+	TrustedProxies []string
+	// GzipLevel is the compression level for gzipped responses (GZIP_LEVEL,
+	// 1 fastest to 9 smallest, default -1 for the library default)
+	GzipLevel int
+	// UniqueTitles rejects creating, renaming or restoring a todo whose title,
+	// ignoring case, is already used by another of the user's live todos
+	// (UNIQUE_TITLES, default false). Completing a recurring todo is rejected
+	// too, as its next occurrence would share its title.
+	UniqueTitles bool
+	// MaxBodyBytes caps the size of request bodies (MAX_BODY_BYTES, default
+	// 1MB, 0 disables the cap). POST /todos/import has its own larger cap.
+	MaxBodyBytes int64
+	// RequestTimeout bounds how long a request may take before it is answered
+	// with 503 (REQUEST_TIMEOUT, a Go duration, default 15s, 0 disables it).
+	// Event streams and WebSockets are exempt.
+	RequestTimeout time.Duration
+	// SlowQueryThreshold is how long a store call may take before it is
+	// logged as a slow query (SLOW_QUERY_MS, in milliseconds, default 200,
+	// 0 disables the log)
+	SlowQueryThreshold time.Duration
+	// CacheSize is how many todos GET /todos/:id keeps cached (CACHE_SIZE,
+	// default 0, which disables the cache)
+	CacheSize int
+	// CacheTTL is how long a cached todo is served (CACHE_TTL, a Go
+	// duration, default 30s)
+	CacheTTL time.Duration
+	// StaleMaxDays caps the days parameter of GET /todos/stale (STALE_MAX_DAYS,
+	// default 365)
+	StaleMaxDays int
+	// BasePath is the prefix every route is served under, such as /api/v1
+	// (BASE_PATH, default none)
+	BasePath string
+	// OpsUnderBasePath also serves /healthz and /metrics under BasePath
+	// (OPS_UNDER_BASE_PATH, default false, which keeps them at the root for
+	// probes and scrapers)
+	OpsUnderBasePath bool
+	// Debug serves GET /debug/config (DEBUG, default false)
+	Debug bool
+
+	// warnings describes each invalid setting loadConfig ignored. They are
+	// logged once main has set up the logger the settings configure.
+	warnings []string
+}
+
+// warnf records a warning about an invalid setting
+func (cfg *Config) warnf(format string, args ...any) {
+	cfg.warnings = append(cfg.warnings, fmt.Sprintf(format, args...))
+}
+
+// defaultConfig returns the settings used when no environment overrides are set
+func defaultConfig() Config {
+	return Config{
+		Addr:               ":8080",
+		PutMode:            putModeReplace,
+		ListMaxRows:        maxPageLimit,
+		ListOverflow:       listOverflowTruncate,
+		ShutdownTimeout:    10 * time.Second,
+		LogLevel:           slog.LevelInfo,
+		GzipLevel:          gzip.DefaultCompression,
+		MaxBodyBytes:       1 << 20,
+		RequestTimeout:     15 * time.Second,
+		SlowQueryThreshold: 200 * time.Millisecond,
+		CacheTTL:           30 * time.Second,
+		StaleMaxDays:       365,
+	}
+}
+
+// loadConfig reads settings from environment variables, falling back to defaults
+func loadConfig() Config {
+	cfg := defaultConfig()
+	if v := os.Getenv("ADDR"); v != "" {
+		if _, _, err := net.SplitHostPort(v); err != nil {
+			cfg.warnf("invalid ADDR %q, using %q", v, cfg.Addr)
+		} else {
+			cfg.Addr = v
+		}
+	} else if v := os.Getenv("PORT"); v != "" {
+		if port, err := strconv.Atoi(v); err != nil || port < 1 || port > 65535 {
+			cfg.warnf("invalid PORT %q, using %q", v, cfg.Addr)
+		} else {
+			cfg.Addr = ":" + v
+		}
+	}
+	if v := os.Getenv("PUT_MODE"); v != "" {
+		switch v {
+		case putModeReplace, putModeMerge:
+			cfg.PutMode = v
+		default:
+			cfg.warnf("invalid PUT_MODE %q, using %q", v, cfg.PutMode)
+		}
+	}
+	if v := os.Getenv("LIST_MAX_ROWS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxPageLimit {
+			cfg.warnf("invalid LIST_MAX_ROWS %q, using %d", v, cfg.ListMaxRows)
+		} else {
+			cfg.ListMaxRows = n
+		}
+	}
+	if v := os.Getenv("LIST_OVERFLOW"); v != "" {
+		switch v {
+		case listOverflowReject, listOverflowTruncate:
+			cfg.ListOverflow = v
+		default:
+			cfg.warnf("invalid LIST_OVERFLOW %q, using %q", v, cfg.ListOverflow)
+		}
+	}
+	cfg.AdminToken = os.Getenv("ADMIN_TOKEN")
+	for _, origin := range strings.Split(os.Getenv("CORS_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.CORSOrigins = append(cfg.CORSOrigins, origin)
+		}
+	}
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy == "" {
+			continue
+		}
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				cfg.warnf("invalid TRUSTED_PROXIES entry %q, ignoring it", proxy)
+				continue
+			}
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+	}
+	if v := os.Getenv("BASE_PATH"); v != "" {
+		cfg.BasePath = normalizeBasePath(v)
+	}
+	if v := os.Getenv("OPS_UNDER_BASE_PATH"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			cfg.warnf("invalid OPS_UNDER_BASE_PATH %q, using %t", v, cfg.OpsUnderBasePath)
+		} else {
+			cfg.OpsUnderBasePath = b
+		}
+	}
+	if v := os.Getenv("DEBUG"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			cfg.warnf("invalid DEBUG %q, using %t", v, cfg.Debug)
+		} else {
+			cfg.Debug = b
+		}
+	}
+	if v := os.Getenv("DONE_AS_STRING"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			cfg.warnf("invalid DONE_AS_STRING %q, using %t", v, cfg.DoneAsString)
+		} else {
+			cfg.DoneAsString = b
+		}
+	}
+	if v := os.Getenv("UNIQUE_TITLES"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			cfg.warnf("invalid UNIQUE_TITLES %q, using %t", v, cfg.UniqueTitles)
+		} else {
+			cfg.UniqueTitles = b
+		}
+	}
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			cfg.warnf("invalid SHUTDOWN_TIMEOUT %q, using %s", v, cfg.ShutdownTimeout)
+		} else {
+			cfg.ShutdownTimeout = d
+		}
+	}
+	if v := os.Getenv("REQUEST_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			cfg.warnf("invalid REQUEST_TIMEOUT %q, using %s", v, cfg.RequestTimeout)
+		} else {
+			cfg.RequestTimeout = d
+		}
+	}
+	if v := os.Getenv("SLOW_QUERY_MS"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			cfg.warnf("invalid SLOW_QUERY_MS %q, using %d", v, cfg.SlowQueryThreshold.Milliseconds())
+		} else {
+			cfg.SlowQueryThreshold = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if v := os.Getenv("CACHE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			cfg.warnf("invalid CACHE_SIZE %q, using %d", v, cfg.CacheSize)
+		} else {
+			cfg.CacheSize = n
+		}
+	}
+	if v := os.Getenv("CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			cfg.warnf("invalid CACHE_TTL %q, using %s", v, cfg.CacheTTL)
+		} else {
+			cfg.CacheTTL = d
+		}
+	}
+	if v := os.Getenv("STALE_MAX_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			cfg.warnf("invalid STALE_MAX_DAYS %q, using %d", v, cfg.StaleMaxDays)
+		} else {
+			cfg.StaleMaxDays = n
+		}
+	}
+	if v := os.Getenv("RATE_LIMIT_RPS"); v != "" {
+		rps, err := strconv.ParseFloat(v, 64)
+		if err != nil || rps < 0 {
+			cfg.warnf("invalid RATE_LIMIT_RPS %q, using %g", v, cfg.RateLimitRPS)
+		} else {
+			cfg.RateLimitRPS = rps
+		}
+	}
+	if v := os.Getenv("RATE_LIMIT_BURST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			cfg.warnf("invalid RATE_LIMIT_BURST %q, using the rate limit", v)
+		} else {
+			cfg.RateLimitBurst = n
+		}
+	}
+	if v := os.Getenv("GZIP_LEVEL"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < gzip.DefaultCompression || n > gzip.BestCompression {
+			cfg.warnf("invalid GZIP_LEVEL %q, using %d", v, cfg.GzipLevel)
+		} else {
+			cfg.GzipLevel = n
+		}
+	}
+	if v := os.Getenv("MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			cfg.warnf("invalid MAX_BODY_BYTES %q, using %d", v, cfg.MaxBodyBytes)
+		} else {
+			cfg.MaxBodyBytes = n
+		}
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			cfg.warnf("invalid LOG_LEVEL %q, using %s", v, cfg.LogLevel)
+		} else {
+			cfg.LogLevel = level
+		}
+	}
+	return cfg
+}
+
+// normalizeBasePath returns path with one leading slash and no trailing
+// slash, so "api/v1/" becomes "/api/v1" and "/" becomes ""
+func normalizeBasePath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// Active configuration, replaced from the environment in main
+var config = defaultConfig()
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		cfg := loadConfig()
+		assert.Equal(t, ":8080", cfg.Addr)
+		assert.Equal(t, putModeReplace, cfg.PutMode)
+		assert.Equal(t, maxPageLimit, cfg.ListMaxRows)
+		assert.Equal(t, listOverflowTruncate, cfg.ListOverflow)
+		assert.False(t, cfg.DoneAsString)
+		assert.Equal(t, 10*time.Second, cfg.ShutdownTimeout)
+		assert.Equal(t, slog.LevelInfo, cfg.LogLevel)
+	})
+
+	t.Run("CORS Origins", func(t *testing.T) {
+		t.Setenv("CORS_ORIGINS", "https://a.example.com, https://b.example.com,")
+		cfg := loadConfig()
+		assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.CORSOrigins)
+	})
+
+	t.Run("Trusted Proxies", func(t *testing.T) {
+		assert.Empty(t, loadConfig().TrustedProxies)
+
+		t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.1, proxy.example.com")
+		assert.Equal(t, []string{"10.0.0.0/8", "192.0.2.1"}, loadConfig().TrustedProxies)
+	})
+
+	t.Run("Rate Limit", func(t *testing.T) {
+		t.Setenv("RATE_LIMIT_RPS", "2.5")
+		t.Setenv("RATE_LIMIT_BURST", "10")
+		cfg := loadConfig()
+		assert.Equal(t, 2.5, cfg.RateLimitRPS)
+		assert.Equal(t, 10, cfg.RateLimitBurst)
+	})
+
+	t.Run("Invalid Rate Limit", func(t *testing.T) {
+		t.Setenv("RATE_LIMIT_RPS", "-1")
+		t.Setenv("RATE_LIMIT_BURST", "0")
+		cfg := loadConfig()
+		assert.Equal(t, 0.0, cfg.RateLimitRPS)
+		assert.Equal(t, 0, cfg.RateLimitBurst)
+	})
+
+	t.Run("Gzip Level", func(t *testing.T) {
+		t.Setenv("GZIP_LEVEL", "9")
+		assert.Equal(t, 9, loadConfig().GzipLevel)
+
+		t.Setenv("GZIP_LEVEL", "10")
+		assert.Equal(t, -1, loadConfig().GzipLevel)
+	})
+
+	t.Run("Log Level", func(t *testing.T) {
+		t.Setenv("LOG_LEVEL", "debug")
+		cfg := loadConfig()
+		assert.Equal(t, slog.LevelDebug, cfg.LogLevel)
+	})
+
+	t.Run("Shutdown Timeout", func(t *testing.T) {
+		t.Setenv("SHUTDOWN_TIMEOUT", "30s")
+		cfg := loadConfig()
+		assert.Equal(t, 30*time.Second, cfg.ShutdownTimeout)
+	})
+
+	t.Run("Invalid Shutdown Timeout Falls Back", func(t *testing.T) {
+		t.Setenv("SHUTDOWN_TIMEOUT", "soon")
+		cfg := loadConfig()
+		assert.Equal(t, 10*time.Second, cfg.ShutdownTimeout)
+	})
+
+	t.Run("Max Body Bytes", func(t *testing.T) {
+		assert.Equal(t, int64(1<<20), loadConfig().MaxBodyBytes)
+
+		t.Setenv("MAX_BODY_BYTES", "4096")
+		assert.Equal(t, int64(4096), loadConfig().MaxBodyBytes)
+
+		t.Setenv("MAX_BODY_BYTES", "-1")
+		assert.Equal(t, int64(1<<20), loadConfig().MaxBodyBytes)
+	})
+
+	t.Run("Request Timeout", func(t *testing.T) {
+		assert.Equal(t, 15*time.Second, loadConfig().RequestTimeout)
+
+		t.Setenv("REQUEST_TIMEOUT", "0")
+		assert.Equal(t, time.Duration(0), loadConfig().RequestTimeout)
+
+		t.Setenv("REQUEST_TIMEOUT", "soon")
+		assert.Equal(t, 15*time.Second, loadConfig().RequestTimeout)
+	})
+
+	t.Run("Slow Query Threshold", func(t *testing.T) {
+		assert.Equal(t, 200*time.Millisecond, loadConfig().SlowQueryThreshold)
+
+		t.Setenv("SLOW_QUERY_MS", "50")
+		assert.Equal(t, 50*time.Millisecond, loadConfig().SlowQueryThreshold)
+
+		t.Setenv("SLOW_QUERY_MS", "fast")
+		assert.Equal(t, 200*time.Millisecond, loadConfig().SlowQueryThreshold)
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		t.Setenv("CACHE_SIZE", "100")
+		t.Setenv("CACHE_TTL", "1m")
+		cfg := loadConfig()
+		assert.Equal(t, 100, cfg.CacheSize)
+		assert.Equal(t, time.Minute, cfg.CacheTTL)
+	})
+
+	t.Run("Invalid Values Are Collected", func(t *testing.T) {
+		assert.Empty(t, loadConfig().warnings)
+
+		t.Setenv("CACHE_SIZE", "lots")
+		t.Setenv("LOG_LEVEL", "loud")
+		assert.Equal(t, []string{`invalid CACHE_SIZE "lots", using 0`, `invalid LOG_LEVEL "loud", using INFO`}, loadConfig().warnings)
+	})
+
+	t.Run("Stale Max Days", func(t *testing.T) {
+		assert.Equal(t, 365, loadConfig().StaleMaxDays)
+
+		t.Setenv("STALE_MAX_DAYS", "90")
+		assert.Equal(t, 90, loadConfig().StaleMaxDays)
+
+		t.Setenv("STALE_MAX_DAYS", "0")
+		assert.Equal(t, 365, loadConfig().StaleMaxDays)
+	})
+
+	t.Run("Base Path", func(t *testing.T) {
+		for value, want := range map[string]string{"/api/v1": "/api/v1", "api/v1/": "/api/v1", "/": ""} {
+			t.Setenv("BASE_PATH", value)
+			assert.Equal(t, want, loadConfig().BasePath, value)
+		}
+
+		t.Setenv("OPS_UNDER_BASE_PATH", "true")
+		assert.True(t, loadConfig().OpsUnderBasePath)
+	})
+
+	t.Run("Debug", func(t *testing.T) {
+		t.Setenv("DEBUG", "true")
+		assert.True(t, loadConfig().Debug)
+
+		t.Setenv("DEBUG", "maybe")
+		assert.False(t, loadConfig().Debug)
+	})
+
+	t.Run("Done As String", func(t *testing.T) {
+		t.Setenv("DONE_AS_STRING", "true")
+		cfg := loadConfig()
+		assert.True(t, cfg.DoneAsString)
+	})
+
+	t.Run("Unique Titles", func(t *testing.T) {
+		t.Setenv("UNIQUE_TITLES", "true")
+		cfg := loadConfig()
+		assert.True(t, cfg.UniqueTitles)
+	})
+
+	t.Run("Port", func(t *testing.T) {
+		t.Setenv("PORT", "9090")
+		cfg := loadConfig()
+		assert.Equal(t, ":9090", cfg.Addr)
+	})
+
+	t.Run("Addr Takes Precedence Over Port", func(t *testing.T) {
+		t.Setenv("PORT", "9090")
+		t.Setenv("ADDR", "127.0.0.1:7070")
+		cfg := loadConfig()
+		assert.Equal(t, "127.0.0.1:7070", cfg.Addr)
+	})
+
+	t.Run("Invalid Port Falls Back", func(t *testing.T) {
+		t.Setenv("PORT", "http")
+		cfg := loadConfig()
+		assert.Equal(t, ":8080", cfg.Addr)
+	})
+
+	t.Run("Invalid Addr Falls Back", func(t *testing.T) {
+		t.Setenv("ADDR", "localhost")
+		cfg := loadConfig()
+		assert.Equal(t, ":8080", cfg.Addr)
+	})
+
+	t.Run("List Cap", func(t *testing.T) {
+		t.Setenv("LIST_MAX_ROWS", "20")
+		t.Setenv("LIST_OVERFLOW", "reject")
+		cfg := loadConfig()
+		assert.Equal(t, 20, cfg.ListMaxRows)
+		assert.Equal(t, listOverflowReject, cfg.ListOverflow)
+
+		t.Setenv("LIST_MAX_ROWS", "1000")
+		assert.Equal(t, maxPageLimit, loadConfig().ListMaxRows)
+	})
+
+	t.Run("Merge Mode", func(t *testing.T) {
+		t.Setenv("PUT_MODE", "merge")
+		cfg := loadConfig()
+		assert.Equal(t, putModeMerge, cfg.PutMode)
+	})
+
+	t.Run("Invalid Mode Falls Back", func(t *testing.T) {
+		t.Setenv("PUT_MODE", "patch")
+		cfg := loadConfig()
+		assert.Equal(t, putModeReplace, cfg.PutMode)
+	})
+}
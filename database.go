@@ -0,0 +1,1394 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrNotFound is returned when no todo has the requested ID
+var ErrNotFound = errors.New("todo not found")
+
+// ErrVersionConflict is returned when a todo no longer has the version the
+// caller expected, because someone else updated it first
+var ErrVersionConflict = errors.New("todo version conflict")
+
+// ErrCategoryNotFound is returned when no category has the requested ID
+var ErrCategoryNotFound = errors.New("category not found")
+
+// ErrTodoExists is returned when creating, renaming, restoring or completing
+// a todo would leave two live todos with the same title while
+// config.UniqueTitles is set
+var ErrTodoExists = errors.New("todo already exists")
+
+// ErrParentNotFound is returned when a todo's parent_id names no live todo
+var ErrParentNotFound = errors.New("parent todo not found")
+
+// ErrParentCycle is returned when a parent_id would make a todo its own ancestor
+var ErrParentCycle = errors.New("parent todo cycle")
+
+// ErrHasSubtasks is returned when deleting a todo that still has subtasks
+// without cascading to them
+var ErrHasSubtasks = errors.New("todo has subtasks")
+
+// DatabaseInterface is the todo storage used by the HTTP handlers
+type DatabaseInterface interface {
+	Ping(ctx context.Context) error
+	GetTodos(ctx context.Context, filter TodoFilter, order TodoOrder, limit, offset int) ([]Todo, error)
+	GetTodosPage(ctx context.Context, filter TodoFilter, order TodoOrder, limit, offset int) (page []Todo, total int, err error)
+	GetTodoByID(ctx context.Context, id int) (*Todo, error)
+	GetSubtasks(ctx context.Context, id int) ([]Todo, error)
+	TotalTodos(ctx context.Context, filter TodoFilter) (int, error)
+	LastModified(ctx context.Context) (time.Time, error)
+	CountTodos(ctx context.Context) (total, done int, err error)
+	CountAllTodos(ctx context.Context) (int, error)
+	SearchTodos(ctx context.Context, query string) ([]Todo, error)
+	GetTodosByTag(ctx context.Context, tag string) ([]Todo, error)
+	GetTodosByTitlePrefix(ctx context.Context, prefix string, limit int) ([]Todo, error)
+	GetTodosDueWithin(ctx context.Context, d time.Duration) ([]Todo, error)
+	GetStaleTodos(ctx context.Context, olderThan time.Time) ([]Todo, error)
+	TitleLengths(ctx context.Context) ([]int, error)
+	CreateTodo(ctx context.Context, todo *Todo) error
+	CreateTodos(ctx context.Context, todos []Todo) ([]Todo, error)
+	DuplicateTodo(ctx context.Context, id int) (*Todo, error)
+	CreateNextOccurrence(ctx context.Context, todo Todo) (*Todo, error)
+	UpdateTodo(ctx context.Context, id, version int, todo Todo) (updated, next *Todo, err error)
+	PatchTodo(ctx context.Context, id int, fields TodoPatch) (patched, next *Todo, err error)
+	UpdateTodosWhere(ctx context.Context, filter TodoFilter, set TodoPatch) (int, error)
+	SetAllDone(ctx context.Context, done bool) (int, error)
+	ReorderTodos(ctx context.Context, ids []int) error
+	ClaimTodos(ctx context.Context, workerID string, n int, lease time.Duration) ([]Todo, error)
+	DeleteTodo(ctx context.Context, id int) error
+	HardDeleteTodo(ctx context.Context, id int) error
+	DeleteTodoCascade(ctx context.Context, id int, hard bool) (int, error)
+	RestoreTodo(ctx context.Context, id int) error
+	DeleteTodos(ctx context.Context, ids []int) (int, error)
+	ClearCompleted(ctx context.Context) (int, error)
+	CreateCategory(ctx context.Context, category *Category) error
+	GetCategories(ctx context.Context) ([]Category, error)
+	GetTodosByCategory(ctx context.Context, id int) ([]Todo, error)
+	DeleteCategory(ctx context.Context, id int) error
+	WithTx(ctx context.Context, fn func(tx TxInterface) error) error
+	Close() error
+}
+
+// TxInterface is the view of the store handed to a WithTx callback
+type TxInterface interface {
+	GetTodos(ctx context.Context, filter TodoFilter, order TodoOrder, limit, offset int) ([]Todo, error)
+	GetTodoByID(ctx context.Context, id int) (*Todo, error)
+	CreateTodo(ctx context.Context, todo *Todo) error
+	CreateTodos(ctx context.Context, todos []Todo) ([]Todo, error)
+	UpdateTodo(ctx context.Context, id, version int, todo Todo) (updated, next *Todo, err error)
+	PatchTodo(ctx context.Context, id int, fields TodoPatch) (patched, next *Todo, err error)
+	DeleteTodo(ctx context.Context, id int) error
+	HardDeleteTodo(ctx context.Context, id int) error
+	RestoreTodo(ctx context.Context, id int) error
+}
+
+// TodoFilter selects todos by field value; nil fields match everything
+type TodoFilter struct {
+	Title *string `json:"title"`
+	Done  *bool   `json:"done"`
+	// Query matches titles containing it, ignoring case. Characters such as
+	// % and _ are matched literally.
+	Query string `json:"query"`
+	// Overdue selects pending todos whose due date has passed (or, when false,
+	// every other todo). Todos without a due date are never overdue.
+	Overdue *bool `json:"overdue"`
+	// Tag selects todos carrying the tag, ignoring case
+	Tag string `json:"tag"`
+	// CreatedAfter and CreatedBefore select todos created at or after, and at
+	// or before, the given times
+	CreatedAfter  *time.Time `json:"created_after"`
+	CreatedBefore *time.Time `json:"created_before"`
+	// IncludeDeleted also matches soft-deleted todos, which are otherwise hidden
+	IncludeDeleted bool `json:"-"`
+	// AfterID selects todos with a greater ID, for cursor pagination
+	AfterID int `json:"-"`
+
+	// user is the owner whose todos match; the store sets it from the context
+	user string
+}
+
+// isEmpty reports whether the filter has no conditions and so matches every todo
+func (f TodoFilter) isEmpty() bool {
+	return f.Title == nil && f.Done == nil && f.Query == "" && f.Overdue == nil && f.Tag == "" && f.CreatedAfter == nil && f.CreatedBefore == nil
+}
+
+// matches reports whether the todo satisfies every condition in the filter
+func (f TodoFilter) matches(todo Todo) bool {
+	if todo.UserID != f.user {
+		return false
+	}
+	if todo.DeletedAt != nil && !f.IncludeDeleted {
+		return false
+	}
+	if todo.ID <= f.AfterID {
+		return false
+	}
+	if f.Title != nil && todo.Title != *f.Title {
+		return false
+	}
+	if f.Query != "" && !strings.Contains(strings.ToLower(todo.Title), strings.ToLower(f.Query)) {
+		return false
+	}
+	if f.Done != nil && todo.Done != *f.Done {
+		return false
+	}
+	if f.Overdue != nil && todo.isOverdue(timeNow()) != *f.Overdue {
+		return false
+	}
+	if f.Tag != "" && !todo.hasTag(strings.ToLower(strings.TrimSpace(f.Tag))) {
+		return false
+	}
+	if f.CreatedAfter != nil && todo.CreatedAt.Before(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && todo.CreatedAt.After(*f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// TodoOrder is the order in which todos are listed. The zero value lists them
+// by ascending position.
+type TodoOrder struct {
+	// Field is one of the keys of sortFields, or empty for position
+	Field string
+	Desc  bool
+}
+
+// sortFields maps each sortable field to a comparison of two todos by it
+var sortFields = map[string]func(a, b Todo) int{
+	"id":    func(a, b Todo) int { return cmp.Compare(a.ID, b.ID) },
+	"title": func(a, b Todo) int { return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)) },
+	"done": func(a, b Todo) int {
+		if a.Done == b.Done {
+			return 0
+		}
+		if a.Done {
+			return 1
+		}
+		return -1
+	},
+	"created_at": func(a, b Todo) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"updated_at": func(a, b Todo) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+	// Todos without a due date sort after those with one
+	"due_date": func(a, b Todo) int {
+		switch {
+		case a.DueDate == nil && b.DueDate == nil:
+			return 0
+		case a.DueDate == nil:
+			return 1
+		case b.DueDate == nil:
+			return -1
+		}
+		return a.DueDate.Compare(*b.DueDate)
+	},
+	// Todos sharing a position keep ID order
+	"position": func(a, b Todo) int { return cmp.Or(cmp.Compare(a.Position, b.Position), cmp.Compare(a.ID, b.ID)) },
+}
+
+// sort orders todos in place. Todos that compare equal keep their relative order.
+func (o TodoOrder) sort(todos []Todo) {
+	if o.Field == "" {
+		o.Field = "position"
+	}
+	compare := sortFields[o.Field]
+	slices.SortStableFunc(todos, func(a, b Todo) int {
+		if o.Desc {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+}
+
+// touch records an update made at now
+func (t *Todo) touch(now time.Time) {
+	t.UpdatedAt = now
+	t.Version++
+}
+
+// timeNow is the clock used for timestamps, replaced in tests
+var timeNow = time.Now
+
+// Database is an in-memory todo store that is safe for concurrent use
+type Database struct {
+	mu             sync.RWMutex
+	todos          []Todo
+	nextID         int
+	categories     []Category
+	nextCategoryID int
+	// modified holds when each user's todos last changed. Hard deletes leave
+	// no updated_at behind, so LastModified needs it on top of the todos.
+	modified map[string]time.Time
+}
+
+// NewDatabase returns a database holding the given todos. Todos without
+// timestamps are stamped with the current time.
+func NewDatabase(todos ...Todo) *Database {
+	db := &Database{todos: append([]Todo(nil), todos...), nextID: 1, nextCategoryID: 1, modified: map[string]time.Time{}}
+	now := timeNow()
+	for i := range db.todos {
+		todo := &db.todos[i]
+		if todo.ID >= db.nextID {
+			db.nextID = todo.ID + 1
+		}
+		if todo.CreatedAt.IsZero() {
+			todo.CreatedAt = now
+		}
+		if todo.UpdatedAt.IsZero() {
+			todo.UpdatedAt = todo.CreatedAt
+		}
+		if todo.Version == 0 {
+			todo.Version = 1
+		}
+		if todo.Position == 0 {
+			todo.Position = todo.ID
+		}
+	}
+	return db
+}
+
+// Ping reports whether the database can serve requests. The in-memory store is
+// always reachable, so it only fails when ctx is already done.
+func (db *Database) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// GetTodos returns up to limit todos matching filter in the given order,
+// skipping the first offset matches
+func (db *Database) GetTodos(ctx context.Context, filter TodoFilter, order TodoOrder, limit, offset int) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	filter.user = userIDFromContext(ctx)
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	page, _ := db.page(filter, order, limit, offset)
+	return page, nil
+}
+
+// GetTodosPage is GetTodos that also returns how many todos match filter in
+// all. Both come from the same read, so the total agrees with the page even
+// while other requests write.
+func (db *Database) GetTodosPage(ctx context.Context, filter TodoFilter, order TodoOrder, limit, offset int) ([]Todo, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	filter.user = userIDFromContext(ctx)
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	page, total := db.page(filter, order, limit, offset)
+	return page, total, nil
+}
+
+// page returns up to limit todos matching filter in the given order, skipping
+// the first offset matches, and the number of matches. Callers must hold mu.
+func (db *Database) page(filter TodoFilter, order TodoOrder, limit, offset int) ([]Todo, int) {
+	matches := []Todo{}
+	for _, todo := range db.todos {
+		if filter.matches(todo) {
+			matches = append(matches, todo)
+		}
+	}
+	order.sort(matches)
+
+	total := len(matches)
+	if offset >= total {
+		return []Todo{}, total
+	}
+	matches = matches[offset:]
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, total
+}
+
+// GetSubtasks returns the live todos whose parent is the todo with the given ID
+func (db *Database) GetSubtasks(ctx context.Context, id int) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user := userIDFromContext(ctx)
+	if db.indexOf(user, id) < 0 {
+		return nil, ErrNotFound
+	}
+	subtasks := []Todo{}
+	for _, todo := range db.todos {
+		if todo.UserID == user && todo.DeletedAt == nil && todo.ParentID != nil && *todo.ParentID == id {
+			subtasks = append(subtasks, todo)
+		}
+	}
+	return subtasks, nil
+}
+
+// GetTodoByID returns the todo with the given ID
+func (db *Database) GetTodoByID(ctx context.Context, id int) (*Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	i := db.indexOf(userIDFromContext(ctx), id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	todo := db.todos[i]
+	return &todo, nil
+}
+
+// TotalTodos returns the number of stored todos matching filter
+func (db *Database) TotalTodos(ctx context.Context, filter TodoFilter) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	filter.user = userIDFromContext(ctx)
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	n := 0
+	for _, todo := range db.todos {
+		if filter.matches(todo) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// LastModified returns when the todos of the user in ctx last changed: the
+// latest updated_at among them, or the time of a later delete. It is zero
+// for a user who has never had a todo.
+func (db *Database) LastModified(ctx context.Context) (time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	user := userIDFromContext(ctx)
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	last := db.modified[user]
+	for _, todo := range db.todos {
+		if todo.UserID == user && todo.UpdatedAt.After(last) {
+			last = todo.UpdatedAt
+		}
+	}
+	return last, nil
+}
+
+// CountTodos returns how many stored todos there are and how many of them
+// are done, in a single pass
+func (db *Database) CountTodos(ctx context.Context) (total, done int, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	filter := TodoFilter{user: userIDFromContext(ctx)}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, todo := range db.todos {
+		if !filter.matches(todo) {
+			continue
+		}
+		total++
+		if todo.Done {
+			done++
+		}
+	}
+	return total, done, nil
+}
+
+// CountAllTodos returns how many live todos are stored across every user. It
+// is not scoped to the user in ctx, so it is only for operational metrics.
+func (db *Database) CountAllTodos(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	n := 0
+	for _, todo := range db.todos {
+		if todo.DeletedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// SearchTodos returns every todo whose title contains query, ignoring case.
+// An empty query returns all todos.
+func (db *Database) SearchTodos(ctx context.Context, query string) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	filter := TodoFilter{Query: query, user: userIDFromContext(ctx)}
+	matches := []Todo{}
+	for _, todo := range db.todos {
+		if filter.matches(todo) {
+			matches = append(matches, todo)
+		}
+	}
+	return matches, nil
+}
+
+// GetTodosByTag returns every todo carrying tag, ignoring case, in ID order
+func (db *Database) GetTodosByTag(ctx context.Context, tag string) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	filter := TodoFilter{Tag: tag, user: userIDFromContext(ctx)}
+	matches := []Todo{}
+	for _, todo := range db.todos {
+		if filter.matches(todo) {
+			matches = append(matches, todo)
+		}
+	}
+	return matches, nil
+}
+
+// GetTodosByTitlePrefix returns up to limit todos whose title starts with prefix, ignoring case
+func (db *Database) GetTodosByTitlePrefix(ctx context.Context, prefix string, limit int) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user := userIDFromContext(ctx)
+	prefix = strings.ToLower(prefix)
+	var matches []Todo
+	for _, todo := range db.todos {
+		if len(matches) == limit {
+			break
+		}
+		if todo.UserID == user && todo.DeletedAt == nil && strings.HasPrefix(strings.ToLower(todo.Title), prefix) {
+			matches = append(matches, todo)
+		}
+	}
+	return matches, nil
+}
+
+// GetTodosDueWithin returns the pending todos due between now and d from now,
+// soonest first. Todos without a due date are left out.
+func (db *Database) GetTodosDueWithin(ctx context.Context, d time.Duration) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user := userIDFromContext(ctx)
+	now := timeNow()
+	end := now.Add(d)
+	matches := []Todo{}
+	for _, todo := range db.todos {
+		if todo.UserID != user || todo.DeletedAt != nil || todo.Done || todo.DueDate == nil {
+			continue
+		}
+		if !todo.DueDate.Before(now) && !todo.DueDate.After(end) {
+			matches = append(matches, todo)
+		}
+	}
+	TodoOrder{Field: "due_date"}.sort(matches)
+	return matches, nil
+}
+
+// GetStaleTodos returns the pending todos created before olderThan, oldest first
+func (db *Database) GetStaleTodos(ctx context.Context, olderThan time.Time) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user := userIDFromContext(ctx)
+	matches := []Todo{}
+	for _, todo := range db.todos {
+		if todo.UserID == user && todo.DeletedAt == nil && !todo.Done && todo.CreatedAt.Before(olderThan) {
+			matches = append(matches, todo)
+		}
+	}
+	TodoOrder{Field: "created_at"}.sort(matches)
+	return matches, nil
+}
+
+// TitleLengths returns the length in characters of every todo title. It is
+// used for admin statistics and so covers every user's todos.
+func (db *Database) TitleLengths(ctx context.Context) ([]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	lengths := make([]int, 0, len(db.todos))
+	for _, todo := range db.todos {
+		if todo.DeletedAt == nil {
+			lengths = append(lengths, utf8.RuneCountInString(todo.Title))
+		}
+	}
+	return lengths, nil
+}
+
+// CreateTodo stores the todo and sets its ID. It fails with
+// ErrCategoryNotFound if the todo names a category that does not exist.
+func (db *Database) CreateTodo(ctx context.Context, todo *Todo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := userIDFromContext(ctx)
+	if todo.CategoryID != nil && db.indexOfCategory(user, *todo.CategoryID) < 0 {
+		return ErrCategoryNotFound
+	}
+	if err := db.checkParent(user, 0, todo.ParentID); err != nil {
+		return err
+	}
+	if db.titleTaken(user, todo.Title, 0) {
+		return ErrTodoExists
+	}
+
+	todo.ID = db.nextID
+	db.nextID++
+	todo.Position = todo.ID
+	todo.UserID = user
+	todo.Tags = normalizeTags(todo.Tags)
+	todo.CreatedAt = timeNow()
+	todo.UpdatedAt = todo.CreatedAt
+	todo.Version = 1
+	db.todos = append(db.todos, *todo)
+	db.markModified(user)
+	return nil
+}
+
+// duplicateSuffix is appended to the title of a duplicated todo
+const duplicateSuffix = " (copy)"
+
+// DuplicateTodo stores a pending copy of the todo with the given ID under a
+// new ID, with duplicateSuffix appended to its title. The title is shortened
+// first if the copy would otherwise exceed the maximum title length.
+func (db *Database) DuplicateTodo(ctx context.Context, id int) (*Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := db.indexOf(userIDFromContext(ctx), id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	source := db.todos[i]
+	title := []rune(source.Title)
+	if keep := maxTitleLength - utf8.RuneCountInString(duplicateSuffix); len(title) > keep {
+		title = title[:keep]
+	}
+	if db.titleTaken(source.UserID, string(title)+duplicateSuffix, 0) {
+		return nil, ErrTodoExists
+	}
+	dup := Todo{
+		ID:         db.nextID,
+		Position:   db.nextID,
+		Title:      string(title) + duplicateSuffix,
+		UserID:     source.UserID,
+		Version:    1,
+		CreatedAt:  timeNow(),
+		DueDate:    source.DueDate,
+		CategoryID: source.CategoryID,
+		Tags:       slices.Clone(source.Tags),
+		Recurrence: source.Recurrence,
+		ParentID:   source.ParentID,
+	}
+	dup.UpdatedAt = dup.CreatedAt
+	db.nextID++
+	db.todos = append(db.todos, dup)
+	db.markModified(dup.UserID)
+	return &dup, nil
+}
+
+// CreateTodos stores all the todos at once and returns them with their IDs set.
+// Nothing is stored if any todo names a category that does not exist.
+func (db *Database) CreateTodos(ctx context.Context, todos []Todo) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := userIDFromContext(ctx)
+	titles := make(map[string]bool, len(todos))
+	for _, todo := range todos {
+		if todo.CategoryID != nil && db.indexOfCategory(user, *todo.CategoryID) < 0 {
+			return nil, ErrCategoryNotFound
+		}
+		if err := db.checkParent(user, 0, todo.ParentID); err != nil {
+			return nil, err
+		}
+		if config.UniqueTitles {
+			title := strings.ToLower(strings.TrimSpace(todo.Title))
+			if titles[title] || db.titleTaken(user, todo.Title, 0) {
+				return nil, ErrTodoExists
+			}
+			titles[title] = true
+		}
+	}
+
+	now := timeNow()
+	created := make([]Todo, 0, len(todos))
+	for _, todo := range todos {
+		todo.ID = db.nextID
+		db.nextID++
+		todo.Position = todo.ID
+		todo.UserID = user
+		todo.Tags = normalizeTags(todo.Tags)
+		todo.CreatedAt = now
+		todo.UpdatedAt = now
+		todo.Version = 1
+		created = append(created, todo)
+	}
+	db.todos = append(db.todos, created...)
+	db.markModified(user)
+	return created, nil
+}
+
+// UpdateTodo overwrites the title, done flag, due date, tags, recurrence, parent
+// and category of the todo with the given ID with those of todo and returns the
+// result. When that completes a recurring todo, next is the occurrence it
+// stored. A non-zero version must match the stored one, or ErrVersionConflict
+// is returned. It fails with ErrTodoExists if the new title is taken.
+func (db *Database) UpdateTodo(ctx context.Context, id, version int, todo Todo) (*Todo, *Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := userIDFromContext(ctx)
+	i := db.indexOf(user, id)
+	if i < 0 {
+		return nil, nil, ErrNotFound
+	}
+	stored := &db.todos[i]
+	if version != 0 && stored.Version != version {
+		return nil, nil, ErrVersionConflict
+	}
+	if todo.CategoryID != nil && db.indexOfCategory(user, *todo.CategoryID) < 0 {
+		return nil, nil, ErrCategoryNotFound
+	}
+	if err := db.checkParent(user, id, todo.ParentID); err != nil {
+		return nil, nil, err
+	}
+	if db.titleTaken(user, todo.Title, id) || recurrenceTaken(stored.Done, todo) {
+		return nil, nil, ErrTodoExists
+	}
+	wasDone := stored.Done
+	stored.Title = todo.Title
+	stored.Done = todo.Done
+	stored.DueDate = todo.DueDate
+	stored.Tags = normalizeTags(todo.Tags)
+	stored.Recurrence = todo.Recurrence
+	stored.ParentID = todo.ParentID
+	stored.CategoryID = todo.CategoryID
+	stored.touch(timeNow())
+	updated := *stored
+	var next *Todo
+	if !wasDone {
+		next = db.recur(updated)
+	}
+	db.markModified(user)
+	return &updated, next, nil
+}
+
+// PatchTodo changes only the provided fields of the todo with the given ID and
+// returns the result, and next as for UpdateTodo. It fails with
+// ErrVersionConflict if fields.Version is set and the stored version differs,
+// and with ErrTodoExists if the new title is taken.
+func (db *Database) PatchTodo(ctx context.Context, id int, fields TodoPatch) (*Todo, *Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := userIDFromContext(ctx)
+	i := db.indexOf(user, id)
+	if i < 0 {
+		return nil, nil, ErrNotFound
+	}
+	if fields.Version != 0 && db.todos[i].Version != fields.Version {
+		return nil, nil, ErrVersionConflict
+	}
+	if category := fields.category(); category != nil && db.indexOfCategory(user, *category) < 0 {
+		return nil, nil, ErrCategoryNotFound
+	}
+	if err := db.checkParent(user, id, fields.parent()); err != nil {
+		return nil, nil, err
+	}
+	wasDone := db.todos[i].Done
+	patched := db.todos[i]
+	fields.apply(&patched)
+	if (fields.Title != nil && db.titleTaken(user, patched.Title, id)) || recurrenceTaken(wasDone, patched) {
+		return nil, nil, ErrTodoExists
+	}
+	fields.apply(&db.todos[i])
+	db.todos[i].touch(timeNow())
+	todo := db.todos[i]
+	var next *Todo
+	if !wasDone {
+		next = db.recur(todo)
+	}
+	db.markModified(user)
+	return &todo, next, nil
+}
+
+// UpdateTodosWhere applies the set fields to every todo matching filter and
+// returns how many changed. Nothing changes if any of them fails a check, such
+// as ErrTodoExists for a title that is taken.
+func (db *Database) UpdateTodosWhere(ctx context.Context, filter TodoFilter, set TodoPatch) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	filter.user = userIDFromContext(ctx)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if category := set.category(); category != nil && db.indexOfCategory(filter.user, *category) < 0 {
+		return 0, ErrCategoryNotFound
+	}
+	matched := 0
+	for _, todo := range db.todos {
+		if !filter.matches(todo) {
+			continue
+		}
+		matched++
+		if err := db.checkParent(filter.user, todo.ID, set.parent()); err != nil {
+			return 0, err
+		}
+		updated := todo
+		set.apply(&updated)
+		// Giving one title to several todos always clashes
+		if set.Title != nil && ((config.UniqueTitles && matched > 1) || db.titleTaken(filter.user, updated.Title, todo.ID)) {
+			return 0, ErrTodoExists
+		}
+		if recurrenceTaken(todo.Done, updated) {
+			return 0, ErrTodoExists
+		}
+	}
+
+	now := timeNow()
+	n := 0
+	for i := range db.todos {
+		if filter.matches(db.todos[i]) {
+			wasDone := db.todos[i].Done
+			set.apply(&db.todos[i])
+			db.todos[i].touch(now)
+			if !wasDone {
+				db.recur(db.todos[i])
+			}
+			n++
+		}
+	}
+	if n > 0 {
+		db.markModified(filter.user)
+	}
+	return n, nil
+}
+
+// SetAllDone marks every live todo of the user in ctx done or pending and
+// returns how many changed. Todos already in that state are left untouched.
+// It fails with ErrTodoExists, changing nothing, if completing a recurring
+// todo would store an occurrence whose title is taken.
+func (db *Database) SetAllDone(ctx context.Context, done bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	user := userIDFromContext(ctx)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, todo := range db.todos {
+		if todo.UserID != user || todo.DeletedAt != nil || todo.Done == done {
+			continue
+		}
+		wasDone := todo.Done
+		todo.Done = done
+		if recurrenceTaken(wasDone, todo) {
+			return 0, ErrTodoExists
+		}
+	}
+
+	now := timeNow()
+	n := 0
+	for i := range db.todos {
+		todo := &db.todos[i]
+		if todo.UserID != user || todo.DeletedAt != nil || todo.Done == done {
+			continue
+		}
+		todo.Done = done
+		todo.touch(now)
+		db.recur(*todo)
+		n++
+	}
+	if n > 0 {
+		db.markModified(user)
+	}
+	return n, nil
+}
+
+// ReorderTodos puts the todos with the given IDs in that order. They swap
+// among the positions they already hold, so todos left out keep theirs. The
+// IDs must not repeat. It fails with ErrNotFound, changing nothing, if any
+// ID names no live todo.
+func (db *Database) ReorderTodos(ctx context.Context, ids []int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := userIDFromContext(ctx)
+	indexes := make([]int, len(ids))
+	positions := make([]int, len(ids))
+	for n, id := range ids {
+		i := db.indexOf(user, id)
+		if i < 0 {
+			return ErrNotFound
+		}
+		indexes[n] = i
+		positions[n] = db.todos[i].Position
+	}
+	slices.Sort(positions)
+
+	now := timeNow()
+	for n, i := range indexes {
+		if db.todos[i].Position != positions[n] {
+			db.todos[i].Position = positions[n]
+			db.todos[i].touch(now)
+		}
+	}
+	db.markModified(user)
+	return nil
+}
+
+// CreateNextOccurrence stores the pending todo that follows the completed
+// recurring todo and returns it. It returns nil for a todo that does not recur,
+// and fails with ErrTodoExists if the occurrence's title is taken.
+func (db *Database) CreateNextOccurrence(ctx context.Context, todo Todo) (*Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if todo.Recurrence == "" {
+		return nil, nil
+	}
+	if db.titleTaken(todo.UserID, todo.Title, 0) {
+		return nil, ErrTodoExists
+	}
+	next := db.insertNextOccurrence(todo)
+	db.markModified(next.UserID)
+	return &next, nil
+}
+
+// recur stores and returns the next occurrence of todo if it is a recurring
+// todo that is now done, and returns nil otherwise. Callers must hold mu.
+func (db *Database) recur(todo Todo) *Todo {
+	if !todo.Done || todo.Recurrence == "" {
+		return nil
+	}
+	next := db.insertNextOccurrence(todo)
+	return &next
+}
+
+// insertNextOccurrence stores and returns the todo following todo. Callers
+// must hold mu and have checked its title.
+func (db *Database) insertNextOccurrence(todo Todo) Todo {
+	next := nextOccurrence(todo, timeNow())
+	next.ID = db.nextID
+	next.Position = next.ID
+	db.nextID++
+	next.Tags = slices.Clone(next.Tags)
+	next.CreatedAt = timeNow()
+	next.UpdatedAt = next.CreatedAt
+	next.Version = 1
+	db.todos = append(db.todos, next)
+	return next
+}
+
+// ClaimTodos marks up to n pending, unclaimed todos as held by workerID until
+// the lease runs out and returns them. Todos whose lease has expired can be
+// claimed again. A claim counts as an update to each claimed todo.
+func (db *Database) ClaimTodos(ctx context.Context, workerID string, n int, lease time.Duration) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := userIDFromContext(ctx)
+	now := timeNow()
+	expires := now.Add(lease)
+	claimed := []Todo{}
+	for i := range db.todos {
+		if len(claimed) == n {
+			break
+		}
+		todo := &db.todos[i]
+		if todo.UserID != user || todo.Done || todo.DeletedAt != nil || (todo.LeaseExpiresAt != nil && todo.LeaseExpiresAt.After(now)) {
+			continue
+		}
+		todo.ClaimedBy = workerID
+		todo.LeaseExpiresAt = &expires
+		todo.touch(now)
+		claimed = append(claimed, *todo)
+	}
+	if len(claimed) > 0 {
+		db.markModified(user)
+	}
+	return claimed, nil
+}
+
+// DeleteTodo soft-deletes the todo with the given ID. It is hidden from reads
+// until restored with RestoreTodo.
+func (db *Database) DeleteTodo(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := userIDFromContext(ctx)
+	i := db.indexOf(user, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	for _, child := range db.descendants(user, id) {
+		if db.todos[child].DeletedAt == nil {
+			return ErrHasSubtasks
+		}
+	}
+	now := timeNow()
+	db.todos[i].DeletedAt = &now
+	db.markModified(user)
+	return nil
+}
+
+// HardDeleteTodo permanently removes the todo with the given ID, whether or
+// not it was soft-deleted
+func (db *Database) HardDeleteTodo(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := userIDFromContext(ctx)
+	i := db.indexOfIncludingDeleted(user, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if len(db.descendants(user, id)) > 0 {
+		return ErrHasSubtasks
+	}
+	db.todos = append(db.todos[:i], db.todos[i+1:]...)
+	db.markModified(user)
+	return nil
+}
+
+// DeleteTodoCascade deletes the todo with the given ID together with all its
+// subtasks, their subtasks and so on, and returns how many todos it deleted.
+// With hard set they are removed for good; otherwise they are soft-deleted.
+func (db *Database) DeleteTodoCascade(ctx context.Context, id int, hard bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := userIDFromContext(ctx)
+	i := db.indexOf(user, id)
+	if hard {
+		i = db.indexOfIncludingDeleted(user, id)
+	}
+	if i < 0 {
+		return 0, ErrNotFound
+	}
+	ids := []int{id}
+	for _, j := range db.descendants(user, id) {
+		ids = append(ids, db.todos[j].ID)
+	}
+	inTree := func(todo Todo) bool { return slices.Contains(ids, todo.ID) }
+	if !hard {
+		return db.deleteWhere(user, inTree)
+	}
+	db.todos = slices.DeleteFunc(db.todos, func(todo Todo) bool {
+		return todo.UserID == user && inTree(todo)
+	})
+	db.markModified(user)
+	return len(ids), nil
+}
+
+// RestoreTodo undoes the soft delete of the todo with the given ID. Restoring
+// a todo that is not deleted does nothing. It fails with ErrTodoExists if
+// another live todo has taken the title in the meantime.
+func (db *Database) RestoreTodo(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := userIDFromContext(ctx)
+	i := db.indexOfIncludingDeleted(user, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if db.todos[i].DeletedAt != nil {
+		if db.titleTaken(user, db.todos[i].Title, id) {
+			return ErrTodoExists
+		}
+		db.todos[i].DeletedAt = nil
+		db.todos[i].touch(timeNow())
+		db.markModified(user)
+	}
+	return nil
+}
+
+// DeleteTodos soft-deletes every todo whose ID is listed and returns how many
+// were deleted. IDs that do not exist are skipped. Like DeleteTodo it fails
+// with ErrHasSubtasks, deleting nothing, if a listed todo has live subtasks
+// that are not listed too.
+func (db *Database) DeleteTodos(ctx context.Context, ids []int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	remove := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	return db.deleteWhere(userIDFromContext(ctx), func(todo Todo) bool { return remove[todo.ID] })
+}
+
+// ClearCompleted soft-deletes every done todo and returns how many were
+// deleted. It fails with ErrHasSubtasks, deleting nothing, if a done todo has
+// pending subtasks.
+func (db *Database) ClearCompleted(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.deleteWhere(userIDFromContext(ctx), func(todo Todo) bool { return todo.Done })
+}
+
+// deleteWhere soft-deletes every live todo of user for which drop returns true
+// and returns how many were deleted. It fails with ErrHasSubtasks, deleting
+// nothing, if that would leave a live subtask under a deleted todo. Callers
+// must hold mu.
+func (db *Database) deleteWhere(user string, drop func(Todo) bool) (int, error) {
+	dropped := func(todo Todo) bool {
+		return todo.UserID == user && todo.DeletedAt == nil && drop(todo)
+	}
+	for _, todo := range db.todos {
+		if !dropped(todo) {
+			continue
+		}
+		for _, child := range db.descendants(user, todo.ID) {
+			if db.todos[child].DeletedAt == nil && !dropped(db.todos[child]) {
+				return 0, ErrHasSubtasks
+			}
+		}
+	}
+
+	now := timeNow()
+	n := 0
+	for i := range db.todos {
+		if dropped(db.todos[i]) {
+			db.todos[i].DeletedAt = &now
+			n++
+		}
+	}
+	if n > 0 {
+		db.markModified(user)
+	}
+	return n, nil
+}
+
+// CreateCategory stores the category for the user in ctx and sets its ID
+func (db *Database) CreateCategory(ctx context.Context, category *Category) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	category.ID = db.nextCategoryID
+	category.UserID = userIDFromContext(ctx)
+	db.nextCategoryID++
+	db.categories = append(db.categories, *category)
+	return nil
+}
+
+// GetCategories returns the categories of the user in ctx in ID order
+func (db *Database) GetCategories(ctx context.Context) ([]Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user := userIDFromContext(ctx)
+	categories := []Category{}
+	for _, category := range db.categories {
+		if category.UserID == user {
+			categories = append(categories, category)
+		}
+	}
+	return categories, nil
+}
+
+// GetTodosByCategory returns every todo in the category with the given ID
+func (db *Database) GetTodosByCategory(ctx context.Context, id int) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user := userIDFromContext(ctx)
+	if db.indexOfCategory(user, id) < 0 {
+		return nil, ErrCategoryNotFound
+	}
+	todos := []Todo{}
+	for _, todo := range db.todos {
+		if todo.UserID == user && todo.DeletedAt == nil && todo.CategoryID != nil && *todo.CategoryID == id {
+			todos = append(todos, todo)
+		}
+	}
+	return todos, nil
+}
+
+// DeleteCategory removes the category of the user in ctx with the given ID.
+// Its todos are kept and moved out of any category, which counts as an update
+// to each of them.
+func (db *Database) DeleteCategory(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := userIDFromContext(ctx)
+	i := db.indexOfCategory(user, id)
+	if i < 0 {
+		return ErrCategoryNotFound
+	}
+	db.categories = append(db.categories[:i], db.categories[i+1:]...)
+	now := timeNow()
+	n := 0
+	for i := range db.todos {
+		if db.todos[i].UserID == user && db.todos[i].CategoryID != nil && *db.todos[i].CategoryID == id {
+			db.todos[i].CategoryID = nil
+			db.todos[i].touch(now)
+			n++
+		}
+	}
+	if n > 0 {
+		db.markModified(user)
+	}
+	return nil
+}
+
+// WithTx runs fn against a copy of the store and keeps its changes only when
+// fn returns nil. If fn fails or panics the store is left as it was. Other
+// callers wait until fn is done, so fn must use tx rather than db.
+func (db *Database) WithTx(ctx context.Context, fn func(tx TxInterface) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx := &Database{
+		todos:          slices.Clone(db.todos),
+		nextID:         db.nextID,
+		categories:     slices.Clone(db.categories),
+		nextCategoryID: db.nextCategoryID,
+		modified:       maps.Clone(db.modified),
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	db.todos, db.nextID = tx.todos, tx.nextID
+	db.categories, db.nextCategoryID = tx.categories, tx.nextCategoryID
+	db.modified = tx.modified
+	return nil
+}
+
+// Close releases the database. The in-memory store holds nothing to release.
+func (db *Database) Close() error {
+	return nil
+}
+
+// markModified records that the todos of user changed just now. Callers must
+// hold mu.
+func (db *Database) markModified(user string) {
+	db.modified[user] = timeNow()
+}
+
+// indexOf returns the position of the live todo of user with the given ID, or
+// -1. Todos owned by someone else are not found. Callers must hold mu.
+func (db *Database) indexOf(user string, id int) int {
+	i := db.indexOfIncludingDeleted(user, id)
+	if i >= 0 && db.todos[i].DeletedAt != nil {
+		return -1
+	}
+	return i
+}
+
+// indexOfIncludingDeleted is indexOf but also finds soft-deleted todos.
+// Callers must hold mu.
+func (db *Database) indexOfIncludingDeleted(user string, id int) int {
+	for i, todo := range db.todos {
+		if todo.ID == id && todo.UserID == user {
+			return i
+		}
+	}
+	return -1
+}
+
+// titleTaken reports whether config.UniqueTitles is set and a live todo of
+// user other than the one with ID except already has the title, ignoring case
+// and surrounding spaces. Pass 0 as except for a todo not stored yet. Callers
+// must hold mu.
+func (db *Database) titleTaken(user, title string, except int) bool {
+	if !config.UniqueTitles {
+		return false
+	}
+	for _, todo := range db.todos {
+		if todo.ID != except && todo.UserID == user && todo.DeletedAt == nil && strings.EqualFold(strings.TrimSpace(todo.Title), strings.TrimSpace(title)) {
+			return true
+		}
+	}
+	return false
+}
+
+// recurrenceTaken reports whether config.UniqueTitles is set and an update
+// completes todo, a recurring todo that was done or not as wasDone says. Its
+// next occurrence would then share its title.
+func recurrenceTaken(wasDone bool, todo Todo) bool {
+	return config.UniqueTitles && !wasDone && todo.Done && todo.Recurrence != ""
+}
+
+// checkParent reports whether the todo of user with the given ID may have
+// parentID as its parent: the parent must be a live todo and must not be the
+// todo itself or one of its subtasks. Pass 0 for a todo not stored yet.
+// Callers must hold mu.
+func (db *Database) checkParent(user string, id int, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+	if db.indexOf(user, *parentID) < 0 {
+		return ErrParentNotFound
+	}
+	for ancestor := parentID; ancestor != nil; {
+		if *ancestor == id {
+			return ErrParentCycle
+		}
+		i := db.indexOfIncludingDeleted(user, *ancestor)
+		if i < 0 {
+			break
+		}
+		ancestor = db.todos[i].ParentID
+	}
+	return nil
+}
+
+// descendants returns the positions of every todo of user below the todo
+// with the given ID, soft-deleted ones included. Callers must hold mu.
+func (db *Database) descendants(user string, id int) []int {
+	var found []int
+	parents := []int{id}
+	for len(parents) > 0 {
+		var children []int
+		for i, todo := range db.todos {
+			if todo.UserID == user && todo.ParentID != nil && slices.Contains(parents, *todo.ParentID) {
+				found = append(found, i)
+				children = append(children, todo.ID)
+			}
+		}
+		parents = children
+	}
+	return found
+}
+
+// indexOfCategory returns the position of user's category with the given ID,
+// or -1. Callers must hold mu.
+func (db *Database) indexOfCategory(user string, id int) int {
+	for i, category := range db.categories {
+		if category.UserID == user && category.ID == id {
+			return i
+		}
+	}
+	return -1
+}
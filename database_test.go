@@ -0,0 +1,532 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// todoIDs returns the IDs of the given todos in order
+func todoIDs(todos []Todo) []int {
+	var ids []int
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+// liveTodoIDs returns the IDs of the given todos that are not soft-deleted
+func liveTodoIDs(todos []Todo) []int {
+	var ids []int
+	for _, todo := range todos {
+		if todo.DeletedAt == nil {
+			ids = append(ids, todo.ID)
+		}
+	}
+	return ids
+}
+
+func TestDatabaseGetTodos(t *testing.T) {
+	db := NewDatabase(
+		Todo{ID: 1, Title: "One"},
+		Todo{ID: 2, Title: "Two"},
+		Todo{ID: 3, Title: "Three"},
+	)
+
+	t.Run("Limit And Offset", func(t *testing.T) {
+		page, err := db.GetTodos(context.Background(), TodoFilter{}, TodoOrder{}, 2, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{2, 3}, todoIDs(page))
+	})
+
+	t.Run("Limit Past End", func(t *testing.T) {
+		page, err := db.GetTodos(context.Background(), TodoFilter{}, TodoOrder{}, 10, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(page))
+	})
+
+	t.Run("Offset Past End", func(t *testing.T) {
+		page, err := db.GetTodos(context.Background(), TodoFilter{}, TodoOrder{}, 10, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, []Todo{}, page)
+	})
+
+	t.Run("Filter Applies Before Offset", func(t *testing.T) {
+		db := NewDatabase(
+			Todo{ID: 1, Title: "One", Done: true},
+			Todo{ID: 2, Title: "Two"},
+			Todo{ID: 3, Title: "Three", Done: true},
+		)
+		done := true
+		page, err := db.GetTodos(context.Background(), TodoFilter{Done: &done}, TodoOrder{}, 10, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{3}, todoIDs(page))
+
+		total, err := db.TotalTodos(context.Background(), TodoFilter{Done: &done})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+	})
+
+	t.Run("Page With Total", func(t *testing.T) {
+		page, total, err := db.GetTodosPage(context.Background(), TodoFilter{}, TodoOrder{}, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{2}, todoIDs(page))
+		assert.Equal(t, 3, total)
+
+		page, total, _ = db.GetTodosPage(context.Background(), TodoFilter{}, TodoOrder{}, 10, 5)
+		assert.Equal(t, []Todo{}, page)
+		assert.Equal(t, 3, total)
+	})
+
+	t.Run("Result Is A Copy", func(t *testing.T) {
+		page, _ := db.GetTodos(context.Background(), TodoFilter{}, TodoOrder{}, 1, 0)
+		page[0].Title = "Changed"
+		again, _ := db.GetTodos(context.Background(), TodoFilter{}, TodoOrder{}, 1, 0)
+		assert.Equal(t, "One", again[0].Title)
+	})
+}
+
+func TestDatabaseCreateTodo(t *testing.T) {
+	t.Run("IDs Continue After Seed", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 5, Title: "Seed"})
+		todo := Todo{Title: "New"}
+		assert.NoError(t, db.CreateTodo(context.Background(), &todo))
+		assert.Equal(t, 6, todo.ID)
+	})
+
+	t.Run("IDs Are Not Reused After Delete", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "One"}, Todo{ID: 2, Title: "Two"})
+		assert.NoError(t, db.HardDeleteTodo(context.Background(), 1))
+		todo := Todo{Title: "New"}
+		assert.NoError(t, db.CreateTodo(context.Background(), &todo))
+		assert.Equal(t, 3, todo.ID)
+	})
+}
+
+func TestDatabaseTimestamps(t *testing.T) {
+	created := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	timeNow = func() time.Time { return created }
+	defer func() { timeNow = time.Now }()
+
+	t.Run("Seed Todos Are Stamped", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "Seed"})
+		page, _ := db.GetTodos(context.Background(), TodoFilter{}, TodoOrder{}, 1, 0)
+		assert.Equal(t, created, page[0].CreatedAt)
+		assert.Equal(t, created, page[0].UpdatedAt)
+	})
+
+	t.Run("Create Sets Both", func(t *testing.T) {
+		db := NewDatabase()
+		todo := Todo{Title: "New"}
+		assert.NoError(t, db.CreateTodo(context.Background(), &todo))
+		assert.Equal(t, created, todo.CreatedAt)
+		assert.Equal(t, todo.CreatedAt, todo.UpdatedAt)
+	})
+
+	t.Run("Update Moves UpdatedAt Only", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "Seed"})
+		timeNow = func() time.Time { return updated }
+		defer func() { timeNow = func() time.Time { return created } }()
+
+		todo, _, err := db.UpdateTodo(context.Background(), 1, 0, Todo{Title: "Seed", Done: true})
+		assert.NoError(t, err)
+		assert.Equal(t, created, todo.CreatedAt)
+		assert.Equal(t, updated, todo.UpdatedAt)
+	})
+}
+
+func TestDatabasePatchTodo(t *testing.T) {
+	due := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Only Provided Fields Change", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "Seed", DueDate: &due})
+		done := true
+		todo, _, err := db.PatchTodo(context.Background(), 1, TodoPatch{Done: &done})
+		assert.NoError(t, err)
+		assert.Equal(t, "Seed", todo.Title)
+		assert.Equal(t, true, todo.Done)
+		assert.Equal(t, &due, todo.DueDate)
+	})
+
+	t.Run("Update Overwrites Every Field", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "Seed", Done: true, DueDate: &due})
+		todo, _, err := db.UpdateTodo(context.Background(), 1, 0, Todo{Title: "New"})
+		assert.NoError(t, err)
+		assert.Equal(t, "New", todo.Title)
+		assert.Equal(t, false, todo.Done)
+		assert.Nil(t, todo.DueDate)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		db := NewDatabase()
+		_, _, err := db.PatchTodo(context.Background(), 1, TodoPatch{})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestDatabaseVersion(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Increments On Every Update", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "Seed"})
+		assert.Equal(t, 1, db.todos[0].Version)
+
+		todo, _, _ := db.UpdateTodo(ctx, 1, 0, Todo{Title: "New"})
+		assert.Equal(t, 2, todo.Version)
+		done := true
+		todo, _, _ = db.PatchTodo(ctx, 1, TodoPatch{Done: &done})
+		assert.Equal(t, 3, todo.Version)
+		db.UpdateTodosWhere(ctx, TodoFilter{}, TodoPatch{Done: &done})
+		assert.Equal(t, 4, db.todos[0].Version)
+	})
+
+	t.Run("Expected Version Must Match", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "Seed"})
+		todo, _, err := db.UpdateTodo(ctx, 1, 1, Todo{Title: "First"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, todo.Version)
+
+		_, _, err = db.UpdateTodo(ctx, 1, 1, Todo{Title: "Second"})
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		assert.Equal(t, "First", db.todos[0].Title)
+		assert.Equal(t, 2, db.todos[0].Version)
+	})
+
+	t.Run("Created Todos Start At One", func(t *testing.T) {
+		db := NewDatabase()
+		todo := Todo{Title: "New"}
+		db.CreateTodo(ctx, &todo)
+		assert.Equal(t, 1, todo.Version)
+	})
+}
+
+func TestDatabaseSearchTodos(t *testing.T) {
+	db := NewDatabase(
+		Todo{ID: 1, Title: "Buy milk"},
+		Todo{ID: 2, Title: "Raise budget 50%"},
+		Todo{ID: 3, Title: "Rename file_name"},
+		Todo{ID: 4, Title: "Raise budget 500"},
+	)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"Case Insensitive", "MILK", []int{1}},
+		{"Percent Is Literal", "50%", []int{2}},
+		{"Underscore Is Literal", "e_n", []int{3}},
+		{"Underscore Does Not Match Any Character", "file name", nil},
+		{"Empty Query Lists All", "", []int{1, 2, 3, 4}},
+		{"No Match", "eggs", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := db.SearchTodos(context.Background(), tt.query)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, todoIDs(matches))
+		})
+	}
+}
+
+func TestDatabaseCanceledContext(t *testing.T) {
+	db := NewDatabase(Todo{ID: 1, Title: "Seed"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := db.GetTodos(ctx, TodoFilter{}, TodoOrder{}, 10, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	err = db.CreateTodo(ctx, &Todo{Title: "New"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	total, _ := db.TotalTodos(context.Background(), TodoFilter{})
+	assert.Equal(t, 1, total)
+}
+
+func TestDatabaseSoftDelete(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Deleted Todos Are Hidden", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "One"}, Todo{ID: 2, Title: "Two"})
+		assert.NoError(t, db.DeleteTodo(ctx, 1))
+		assert.NotNil(t, db.todos[0].DeletedAt)
+
+		page, _ := db.GetTodos(ctx, TodoFilter{}, TodoOrder{}, 10, 0)
+		assert.Equal(t, []int{2}, todoIDs(page))
+		total, _ := db.TotalTodos(ctx, TodoFilter{})
+		assert.Equal(t, 1, total)
+		matches, _ := db.GetTodosByTitlePrefix(ctx, "o", 10)
+		assert.Empty(t, matches)
+		_, _, err := db.PatchTodo(ctx, 1, TodoPatch{})
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, db.DeleteTodo(ctx, 1), ErrNotFound)
+
+		page, _ = db.GetTodos(ctx, TodoFilter{IncludeDeleted: true}, TodoOrder{}, 10, 0)
+		assert.Equal(t, []int{1, 2}, todoIDs(page))
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "One"})
+		assert.NoError(t, db.DeleteTodo(ctx, 1))
+		assert.NoError(t, db.RestoreTodo(ctx, 1))
+		assert.Nil(t, db.todos[0].DeletedAt)
+		assert.NoError(t, db.RestoreTodo(ctx, 1))
+		assert.ErrorIs(t, db.RestoreTodo(ctx, 999), ErrNotFound)
+	})
+
+	t.Run("Hard Delete", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "One"}, Todo{ID: 2, Title: "Two"})
+		assert.NoError(t, db.DeleteTodo(ctx, 1))
+		assert.NoError(t, db.HardDeleteTodo(ctx, 1))
+		assert.NoError(t, db.HardDeleteTodo(ctx, 2))
+		assert.Empty(t, db.todos)
+		assert.ErrorIs(t, db.HardDeleteTodo(ctx, 1), ErrNotFound)
+		assert.ErrorIs(t, db.RestoreTodo(ctx, 1), ErrNotFound)
+	})
+}
+
+func TestDatabaseGetTodosByTag(t *testing.T) {
+	ctx := context.Background()
+	db := NewDatabase(Todo{ID: 1, Title: "One"})
+	db.CreateTodos(ctx, []Todo{
+		{Title: "Two", Tags: []string{"Work", "URGENT"}},
+		{Title: "Three", Tags: []string{"work"}},
+	})
+	db.DeleteTodo(ctx, 3)
+
+	matches, err := db.GetTodosByTag(ctx, "Work")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2}, todoIDs(matches))
+	assert.Equal(t, []string{"work", "urgent"}, matches[0].Tags)
+
+	matches, _ = db.GetTodosByTag(ctx, "missing")
+	assert.Empty(t, matches)
+}
+
+func TestTodoOrder(t *testing.T) {
+	due := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := due.Add(time.Hour)
+	todos := []Todo{
+		{ID: 1, Title: "b"},
+		{ID: 2, Title: "a", DueDate: &later},
+		{ID: 3, Title: "B", DueDate: &due, Done: true},
+	}
+
+	TodoOrder{Field: "due_date"}.sort(todos)
+	assert.Equal(t, []int{3, 2, 1}, todoIDs(todos))
+
+	TodoOrder{Field: "title"}.sort(todos)
+	assert.Equal(t, []int{2, 3, 1}, todoIDs(todos))
+
+	TodoOrder{Field: "done", Desc: true}.sort(todos)
+	assert.Equal(t, []int{3, 2, 1}, todoIDs(todos))
+
+	TodoOrder{}.sort(todos)
+	assert.Equal(t, []int{1, 2, 3}, todoIDs(todos))
+	todos[0].Position, todos[1].Position, todos[2].Position = 2, 1, 2
+	TodoOrder{}.sort(todos)
+	assert.Equal(t, []int{2, 1, 3}, todoIDs(todos))
+}
+
+func TestDatabaseLastModified(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return created }
+	defer func() { timeNow = time.Now }()
+	db := NewDatabase(Todo{ID: 1, Title: "Seed"}, Todo{ID: 2, Title: "Other"})
+
+	last, err := db.LastModified(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, created, last)
+
+	deleted := created.Add(time.Minute)
+	timeNow = func() time.Time { return deleted }
+	db.HardDeleteTodo(ctx, 2)
+	last, _ = db.LastModified(ctx)
+	assert.Equal(t, deleted, last)
+
+	last, _ = db.LastModified(withUserID(ctx, "someone-else"))
+	assert.True(t, last.IsZero())
+}
+
+func TestDatabaseWithTx(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Commit", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "Seed"})
+		err := db.WithTx(ctx, func(tx TxInterface) error {
+			if err := tx.CreateTodo(ctx, &Todo{Title: "New"}); err != nil {
+				return err
+			}
+			return tx.DeleteTodo(ctx, 1)
+		})
+		assert.NoError(t, err)
+
+		todos, _ := db.GetTodos(ctx, TodoFilter{}, TodoOrder{}, 10, 0)
+		assert.Equal(t, []int{2}, todoIDs(todos))
+	})
+
+	t.Run("Rollback On Error", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "Seed"})
+		err := db.WithTx(ctx, func(tx TxInterface) error {
+			tx.CreateTodo(ctx, &Todo{Title: "New"})
+			_, _, err := tx.PatchTodo(ctx, 99, TodoPatch{})
+			return err
+		})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		todos, _ := db.GetTodos(ctx, TodoFilter{}, TodoOrder{}, 10, 0)
+		assert.Equal(t, []int{1}, todoIDs(todos))
+
+		todo := Todo{Title: "After"}
+		db.CreateTodo(ctx, &todo)
+		assert.Equal(t, 2, todo.ID)
+	})
+
+	t.Run("Rollback On Panic", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "Seed"})
+		assert.Panics(t, func() {
+			db.WithTx(ctx, func(tx TxInterface) error {
+				tx.HardDeleteTodo(ctx, 1)
+				panic("boom")
+			})
+		})
+
+		total, _ := db.TotalTodos(ctx, TodoFilter{})
+		assert.Equal(t, 1, total)
+	})
+}
+
+func TestDatabaseRecurrence(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	t.Run("Due Dates Advance By Rule", func(t *testing.T) {
+		due := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+		tests := []struct {
+			rule string
+			want time.Time
+		}{
+			{recurrenceDaily, time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+			{recurrenceWeekly, time.Date(2024, 2, 7, 9, 0, 0, 0, time.UTC)},
+			{recurrenceMonthly, time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC)},
+		}
+		for _, tt := range tests {
+			db := NewDatabase()
+			next, err := db.CreateNextOccurrence(ctx, Todo{ID: 7, Title: "Repeat", Done: true, DueDate: &due, Recurrence: tt.rule})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, *next.DueDate, tt.rule)
+			assert.Equal(t, 1, next.ID)
+		}
+	})
+
+	t.Run("No Due Date Advances From Now", func(t *testing.T) {
+		db := NewDatabase()
+		next, _ := db.CreateNextOccurrence(ctx, Todo{Title: "Repeat", Done: true, Recurrence: recurrenceDaily})
+		assert.Equal(t, now.AddDate(0, 0, 1), *next.DueDate)
+	})
+
+	t.Run("Not Recurring", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "Once"})
+		next, err := db.CreateNextOccurrence(ctx, Todo{ID: 1, Title: "Once", Done: true})
+		assert.NoError(t, err)
+		assert.Nil(t, next)
+
+		db.UpdateTodo(ctx, 1, 0, Todo{Title: "Once", Done: true})
+		total, _ := db.TotalTodos(ctx, TodoFilter{})
+		assert.Equal(t, 1, total)
+	})
+
+	t.Run("Update Schedules The Next", func(t *testing.T) {
+		db := NewDatabase(Todo{ID: 1, Title: "Repeat", Recurrence: recurrenceDaily})
+		_, next, err := db.UpdateTodo(ctx, 1, 0, Todo{Title: "Repeat", Done: true, Recurrence: recurrenceDaily})
+		assert.NoError(t, err)
+		todos, _ := db.GetTodos(ctx, TodoFilter{}, TodoOrder{}, 10, 0)
+		assert.Equal(t, []int{1, 2}, todoIDs(todos))
+		assert.False(t, todos[1].Done)
+		assert.Equal(t, todos[1], *next)
+	})
+}
+
+func TestDatabaseSubtasks(t *testing.T) {
+	ctx := context.Background()
+	// 1 <- 2 <- 3, and 4 on its own
+	newTree := func() *Database {
+		one, two := 1, 2
+		return NewDatabase(
+			Todo{ID: 1, Title: "Plan trip"},
+			Todo{ID: 2, Title: "Book flights", ParentID: &one},
+			Todo{ID: 3, Title: "Compare fares", ParentID: &two},
+			Todo{ID: 4, Title: "Unrelated"},
+		)
+	}
+
+	t.Run("Cycles Are Rejected", func(t *testing.T) {
+		db := newTree()
+		tests := []struct {
+			name   string
+			id     int
+			parent int
+			want   error
+		}{
+			{"Own Parent", 1, 1, ErrParentCycle},
+			{"Child As Parent", 1, 2, ErrParentCycle},
+			{"Grandchild As Parent", 1, 3, ErrParentCycle},
+			{"Missing Parent", 1, 99, ErrParentNotFound},
+			{"Sibling Tree", 4, 3, nil},
+			{"Move Up", 3, 1, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				parent := tt.parent
+				_, _, err := newTree().PatchTodo(ctx, tt.id, TodoPatch{ParentID: &parent})
+				if tt.want == nil {
+					assert.NoError(t, err)
+				} else {
+					assert.ErrorIs(t, err, tt.want)
+				}
+			})
+		}
+
+		three := 3
+		_, err := db.UpdateTodosWhere(ctx, TodoFilter{}, TodoPatch{ParentID: &three})
+		assert.ErrorIs(t, err, ErrParentCycle)
+		todo, _ := db.GetTodoByID(ctx, 4)
+		assert.Nil(t, todo.ParentID)
+	})
+
+	t.Run("Detach", func(t *testing.T) {
+		db := newTree()
+		zero := 0
+		todo, _, err := db.PatchTodo(ctx, 2, TodoPatch{ParentID: &zero})
+		assert.NoError(t, err)
+		assert.Nil(t, todo.ParentID)
+	})
+
+	t.Run("Delete Blocked By Subtasks", func(t *testing.T) {
+		db := newTree()
+		assert.ErrorIs(t, db.DeleteTodo(ctx, 1), ErrHasSubtasks)
+		assert.ErrorIs(t, db.HardDeleteTodo(ctx, 2), ErrHasSubtasks)
+		assert.NoError(t, db.DeleteTodo(ctx, 3))
+		assert.NoError(t, db.DeleteTodo(ctx, 2))
+	})
+
+	t.Run("Cascade", func(t *testing.T) {
+		db := newTree()
+		n, err := db.DeleteTodoCascade(ctx, 1, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, []int{4}, liveTodoIDs(db.todos))
+
+		n, err = db.DeleteTodoCascade(ctx, 1, true)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, []int{4}, todoIDs(db.todos))
+	})
+}
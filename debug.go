@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// debugConfigPath is where the resolved configuration is served when Debug is on
+const debugConfigPath = "/debug/config"
+
+// redacted stands in for secrets in GET /debug/config
+const redacted = "[redacted]"
+
+// getDebugConfig handles GET /debug/config, showing the configuration the
+// server resolved from the environment. It is only routed when config.Debug
+// is set.
+func getDebugConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, debugConfig(config))
+}
+
+// debugConfig returns cfg keyed by the environment variable behind each
+// setting, with secrets redacted. Unset secrets are shown as empty so that a
+// missing one can still be spotted.
+func debugConfig(cfg Config) gin.H {
+	adminToken := ""
+	if cfg.AdminToken != "" {
+		adminToken = redacted
+	}
+	return gin.H{
+		"ADDR":                cfg.Addr,
+		"PUT_MODE":            cfg.PutMode,
+		"LIST_MAX_ROWS":       cfg.ListMaxRows,
+		"LIST_OVERFLOW":       cfg.ListOverflow,
+		"DONE_AS_STRING":      cfg.DoneAsString,
+		"ADMIN_TOKEN":         adminToken,
+		"SHUTDOWN_TIMEOUT":    cfg.ShutdownTimeout.String(),
+		"LOG_LEVEL":           cfg.LogLevel.String(),
+		"CORS_ORIGINS":        cfg.CORSOrigins,
+		"RATE_LIMIT_RPS":      cfg.RateLimitRPS,
+		"RATE_LIMIT_BURST":    cfg.RateLimitBurst,
+		"TRUSTED_PROXIES":     cfg.TrustedProxies,
+		"GZIP_LEVEL":          cfg.GzipLevel,
+		"UNIQUE_TITLES":       cfg.UniqueTitles,
+		"MAX_BODY_BYTES":      cfg.MaxBodyBytes,
+		"REQUEST_TIMEOUT":     cfg.RequestTimeout.String(),
+		"SLOW_QUERY_MS":       cfg.SlowQueryThreshold.Milliseconds(),
+		"CACHE_SIZE":          cfg.CacheSize,
+		"CACHE_TTL":           cfg.CacheTTL.String(),
+		"STALE_MAX_DAYS":      cfg.StaleMaxDays,
+		"BASE_PATH":           cfg.BasePath,
+		"OPS_UNDER_BASE_PATH": cfg.OpsUnderBasePath,
+		"DEBUG":               cfg.Debug,
+	}
+}
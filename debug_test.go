@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDebugConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Not Found When Debug Is Off", func(t *testing.T) {
+		r := SetupRouter()
+		req, _ := http.NewRequest("GET", "/debug/config", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Redacts Secrets", func(t *testing.T) {
+		config.Debug = true
+		config.AdminToken = "s3cret"
+		config.RateLimitRPS = 5
+		defer func() { config = defaultConfig() }()
+		r := SetupRouter()
+
+		req, _ := http.NewRequest("GET", "/debug/config", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "s3cret")
+
+		var body map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, redacted, body["ADMIN_TOKEN"])
+		assert.Equal(t, ":8080", body["ADDR"])
+		assert.Equal(t, float64(5), body["RATE_LIMIT_RPS"])
+		assert.Equal(t, "15s", body["REQUEST_TIMEOUT"])
+	})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Error codes for request bodies that could not be bound
+const (
+	codeMalformedJSON    = "malformed_json"
+	codeValidationFailed = "validation_failed"
+)
+
+// apiError is the body of every error response, wrapped as {"error": ...} in
+// JSON and sent as an error element in XML
+type apiError struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Code    string   `json:"code" xml:"code"`
+	Message string   `json:"message" xml:"message"`
+	// Fields maps each invalid request field to its problem. XML responses
+	// leave it out; their message names the fields too.
+	Fields map[string]string `json:"fields,omitempty" xml:"-"`
+}
+
+// respondError aborts the request with an error envelope whose code is
+// derived from the status, e.g. "not_found" for 404
+func respondError(c *gin.Context, status int, message string) {
+	abortWithError(c, status, apiError{Code: errorCode(status), Message: message})
+}
+
+// abortWithError aborts the request with body as its error, in XML if the
+// client asks for it and in JSON otherwise
+func abortWithError(c *gin.Context, status int, body apiError) {
+	if wantsXML(c) {
+		c.Abort()
+		c.XML(status, body)
+		return
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": body})
+}
+
+// respondInternalError logs err with the request ID and aborts the request
+// with a generic 500, so storage details are never leaked to clients. A
+// request that ran out of time gets a 503 instead.
+func respondInternalError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	slog.Default().LogAttrs(c.Request.Context(), slog.LevelError, "internal error",
+		slog.String("error", err.Error()),
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+		slog.String("request_id", c.GetString(requestIDKey)),
+	)
+	if errors.Is(err, context.DeadlineExceeded) {
+		respondTimeout(c)
+		return
+	}
+	respondError(c, http.StatusInternalServerError, "internal server error")
+}
+
+// respondTimeout aborts the request with a 503 because it took longer than
+// config.RequestTimeout
+func respondTimeout(c *gin.Context) {
+	respondError(c, http.StatusServiceUnavailable, "request timed out")
+}
+
+// respondBindError aborts the request with a 400 describing why its body
+// could not be bound, or a 413 if it was too large
+func respondBindError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondBodyTooLarge(c, tooLarge.Limit)
+		return
+	}
+
+	fields := bindErrorFields(err)
+	if len(fields) == 0 {
+		abortWithError(c, http.StatusBadRequest, apiError{Code: codeMalformedJSON, Message: malformedBodyMessage(err)})
+		return
+	}
+
+	abortWithError(c, http.StatusBadRequest, apiError{
+		Code:    codeValidationFailed,
+		Message: fieldsMessage(fields),
+		Fields:  fields,
+	})
+}
+
+// respondBodyTooLarge aborts the request with a 413 naming the body size limit
+func respondBodyTooLarge(c *gin.Context, limit int64) {
+	respondError(c, http.StatusRequestEntityTooLarge, "request body exceeds the maximum of "+strconv.FormatInt(limit, 10)+" bytes")
+}
+
+// fieldsMessage joins field problems into one message ordered by field,
+// e.g. "done is required; title is required"
+func fieldsMessage(fields map[string]string) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	problems := make([]string, 0, len(names))
+	for _, name := range names {
+		problems = append(problems, name+" "+fields[name])
+	}
+	return strings.Join(problems, "; ")
+}
+
+// bindErrorFields returns the problem with each invalid field, or nil when
+// err is not about specific fields
+func bindErrorFields(err error) map[string]string {
+	// Gin collects the failures of a slice body without their item index
+	var sliceErrs binding.SliceValidationError
+	if errors.As(err, &sliceErrs) && len(sliceErrs) > 0 {
+		return bindErrorFields(sliceErrs[0])
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return map[string]string{typeErr.Field: "must be a " + typeErr.Type.String()}
+	}
+
+	// encoding/json has no error type for unknown fields, only this message
+	if field, ok := strings.CutPrefix(err.Error(), `json: unknown field "`); ok {
+		return map[string]string{strings.TrimSuffix(field, `"`): "is not a known field"}
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return nil
+	}
+	fields := make(map[string]string, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		fields[fe.Field()] = fieldProblem(fe)
+	}
+	return fields
+}
+
+// malformedBodyMessage describes a body that could not be decoded at all
+func malformedBodyMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var timeErr *time.ParseError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return "request body is not valid JSON"
+	case errors.As(err, &timeErr):
+		return "timestamps must be in RFC 3339 format"
+	default:
+		return err.Error()
+	}
+}
+
+// errorCode turns an HTTP status into a snake_case error code
+func errorCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// brokenDatabase is a database whose listing always fails
+type brokenDatabase struct {
+	DatabaseInterface
+}
+
+func (brokenDatabase) GetTodosPage(ctx context.Context, filter TodoFilter, order TodoOrder, limit, offset int) ([]Todo, int, error) {
+	return nil, 0, errors.New(`pq: relation "todos" does not exist`)
+}
+
+// errorBody decodes an error envelope
+func errorBody(w *httptest.ResponseRecorder) apiError {
+	var body struct {
+		Error apiError `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return body.Error
+}
+
+func TestErrorResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Malformed JSON", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(`{"title": `))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, apiError{Code: codeMalformedJSON, Message: "request body is not valid JSON"}, errorBody(w))
+	})
+
+	t.Run("Validation Failure Lists Fields", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(`{"title": ""}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		body := errorBody(w)
+		assert.Equal(t, codeValidationFailed, body.Code)
+		assert.Equal(t, "done is required; title is required", body.Message)
+		assert.Equal(t, map[string]string{"title": "is required", "done": "is required"}, body.Fields)
+	})
+
+	t.Run("Wrong Field Type", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(`{"title": "New Todo", "done": "yes"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		body := errorBody(w)
+		assert.Equal(t, codeValidationFailed, body.Code)
+		assert.Equal(t, map[string]string{"done": "must be a bool"}, body.Fields)
+	})
+
+	t.Run("Unknown Field", func(t *testing.T) {
+		for _, method := range []string{"POST", "PUT", "PATCH"} {
+			resetTodos()
+			path := "/todos/1"
+			if method == "POST" {
+				path = "/todos"
+			}
+			req, _ := http.NewRequest(method, path, strings.NewReader(`{"titel": "New Todo", "title": "New Todo", "done": false}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, method)
+			assert.Equal(t, apiError{
+				Code:    codeValidationFailed,
+				Message: "titel is not a known field",
+				Fields:  map[string]string{"titel": "is not a known field"},
+			}, errorBody(w), method)
+			assert.Equal(t, "Learn Go", testDB.todos[0].Title, method)
+		}
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("DELETE", "/todos/999", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, apiError{Code: "not_found", Message: "Todo not found"}, errorBody(w))
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/999", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/xml")
+		assert.Equal(t, "<error><code>not_found</code><message>Todo not found</message></error>", w.Body.String())
+	})
+
+	t.Run("Internal Error Is Not Leaked", func(t *testing.T) {
+		resetTodos()
+		db = brokenDatabase{db}
+		var buf bytes.Buffer
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		req.Header.Set(requestIDHeader, "req-500")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, apiError{Code: "internal_server_error", Message: "internal server error"}, errorBody(w))
+		assert.NotContains(t, w.Body.String(), "pq:")
+		assert.Contains(t, buf.String(), `"msg":"internal error"`)
+		assert.Contains(t, buf.String(), `pq: relation \"todos\" does not exist`)
+		assert.Contains(t, buf.String(), `"request_id":"req-500"`)
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// todoETag returns a strong ETag for the todo. UpdatedAt moves on every
+// change, so the tag changes whenever the todo does.
+func todoETag(todo Todo) string {
+	h := sha256.New()
+	h.Write([]byte(strconv.Itoa(todo.ID)))
+	h.Write([]byte{0})
+	h.Write([]byte(todo.Title))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatBool(todo.Done)))
+	h.Write([]byte{0})
+	h.Write([]byte(todo.UpdatedAt.UTC().Format(time.RFC3339Nano)))
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header value names
+// etag. The value may list several tags or be "*", which matches any tag;
+// weak tags compare by their opaque part.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModifiedSince reports whether an If-Modified-Since header value is at or
+// after lastModified. HTTP dates only carry whole seconds, so lastModified is
+// compared at that precision. An empty or malformed value never matches.
+func notModifiedSince(header string, lastModified time.Time) bool {
+	if header == "" {
+		return false
+	}
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
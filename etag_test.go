@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtagMatches(t *testing.T) {
+	etag := `"abc"`
+	assert.True(t, etagMatches(`"abc"`, etag))
+	assert.True(t, etagMatches(`"xyz", "abc"`, etag))
+	assert.True(t, etagMatches(`W/"abc"`, etag))
+	assert.True(t, etagMatches(`*`, etag))
+	assert.False(t, etagMatches(`"xyz"`, etag))
+	assert.False(t, etagMatches(`abc`, etag))
+	assert.False(t, etagMatches(``, etag))
+}
+
+func TestNotModifiedSince(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+	assert.True(t, notModifiedSince("Wed, 01 May 2024 12:00:00 GMT", modified))
+	assert.True(t, notModifiedSince("Wed, 01 May 2024 12:00:01 GMT", modified))
+	assert.False(t, notModifiedSince("Wed, 01 May 2024 11:59:59 GMT", modified))
+	assert.False(t, notModifiedSince("yesterday", modified))
+	assert.False(t, notModifiedSince("", modified))
+}
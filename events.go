@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Types of todoEvent
+const (
+	eventCreated = "created"
+	eventUpdated = "updated"
+	eventDeleted = "deleted"
+)
+
+// todoEvent is a change pushed to GET /todos/events subscribers. ID names the
+// todo that changed and Todo, when set, is its new state. Changes to several
+// todos at once carry neither, and clients should refetch the list.
+type todoEvent struct {
+	Type string `json:"type"`
+	ID   int    `json:"id,omitempty"`
+	Todo *Todo  `json:"todo,omitempty"`
+}
+
+// eventBufferSize is how many events a subscriber may fall behind by before
+// it is dropped
+const eventBufferSize = 64
+
+// eventKeepAlive is how often an idle event stream sends a comment, so that
+// proxies do not time it out
+const eventKeepAlive = 30 * time.Second
+
+// eventHub fans todo events out to the subscribers of the user they belong to.
+// Publishing never blocks: a subscriber too slow to keep up is dropped.
+type eventHub struct {
+	mu sync.Mutex
+	// subscribers maps each subscriber's channel to the user it listens for
+	subscribers map[chan todoEvent]string
+}
+
+// newEventHub returns a hub without subscribers
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: map[chan todoEvent]string{}}
+}
+
+// events is the hub that handlers publish to after a successful write
+var events = newEventHub()
+
+// subscribe returns a channel receiving the events of user. It is closed when
+// the subscriber is dropped or the hub is closed.
+func (h *eventHub) subscribe(user string) chan todoEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan todoEvent, eventBufferSize)
+	h.subscribers[ch] = user
+	return ch
+}
+
+// unsubscribe removes the subscriber and closes its channel. It does nothing
+// if the subscriber was already dropped.
+func (h *eventHub) unsubscribe(ch chan todoEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends event to every subscriber of user. Subscribers whose buffer
+// is full are dropped rather than waited for.
+func (h *eventHub) publish(user string, event todoEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch, subscriber := range h.subscribers {
+		if subscriber != user {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// close drops every subscriber, ending their streams
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publishEvent notifies the subscribers of the user making the request
+func publishEvent(c *gin.Context, event todoEvent) {
+	events.publish(userIDFromContext(c.Request.Context()), event)
+}
+
+// streamTodoEvents handles GET /todos/events, streaming the user's todo
+// changes as Server-Sent Events until the client disconnects or falls too far
+// behind. Events are in the shape of the route's API version.
+func streamTodoEvents(c *gin.Context) {
+	version := apiVersion(c)
+	ch := events.subscribe(userIDFromContext(c.Request.Context()))
+	defer events.unsubscribe(ch)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, forAPIVersion(version, event))
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventHub(t *testing.T) {
+	t.Run("Delivers To Same User", func(t *testing.T) {
+		hub := newEventHub()
+		alice := hub.subscribe("alice")
+		bob := hub.subscribe("bob")
+		hub.publish("alice", todoEvent{Type: eventDeleted, ID: 1})
+
+		assert.Equal(t, todoEvent{Type: eventDeleted, ID: 1}, <-alice)
+		assert.Empty(t, bob)
+	})
+
+	t.Run("Drops Slow Subscriber", func(t *testing.T) {
+		hub := newEventHub()
+		ch := hub.subscribe("")
+		for i := 0; i <= eventBufferSize; i++ {
+			hub.publish("", todoEvent{Type: eventUpdated, ID: i})
+		}
+
+		n := 0
+		for range ch {
+			n++
+		}
+		assert.Equal(t, eventBufferSize, n)
+		hub.unsubscribe(ch)
+	})
+
+	t.Run("Close Ends Subscriptions", func(t *testing.T) {
+		hub := newEventHub()
+		ch := hub.subscribe("")
+		hub.close()
+
+		_, ok := <-ch
+		assert.False(t, ok)
+	})
+}
+
+func TestStreamTodoEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resetTodos()
+	srv := httptest.NewServer(SetupRouter())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/todos/events")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	post, err := http.Post(srv.URL+"/todos", "application/json", strings.NewReader(`{"title": "Streamed"}`))
+	assert.NoError(t, err)
+	post.Body.Close()
+
+	body := bufio.NewReader(resp.Body)
+	event, _ := body.ReadString('\n')
+	data, _ := body.ReadString('\n')
+	assert.Equal(t, "event:created\n", event)
+	assert.Contains(t, data, `"type":"created","id":3`)
+	assert.Contains(t, data, `"title":"Streamed"`)
+	assert.NotContains(t, data, "due_date")
+}
+
+func TestStreamTodoEventsV2(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resetTodos()
+	srv := httptest.NewServer(SetupRouter())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v2/todos/events")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	post, err := http.Post(srv.URL+"/todos", "application/json", strings.NewReader(`{"title": "Streamed"}`))
+	assert.NoError(t, err)
+	post.Body.Close()
+
+	body := bufio.NewReader(resp.Body)
+	body.ReadString('\n')
+	data, _ := body.ReadString('\n')
+	assert.Contains(t, data, `"title":"Streamed"`)
+	assert.Contains(t, data, `"due_date":null`)
+}
+
+func TestStreamTodoEventsNextOccurrence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resetTodos(Todo{ID: 3, Title: "Take out bins", Recurrence: recurrenceWeekly})
+	srv := httptest.NewServer(SetupRouter())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/todos/events")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	req, _ := http.NewRequest("PATCH", srv.URL+"/todos/3", strings.NewReader(`{"done": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	patch, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	patch.Body.Close()
+
+	body := bufio.NewReader(resp.Body)
+	next := func() (string, string) {
+		event, _ := body.ReadString('\n')
+		data, _ := body.ReadString('\n')
+		body.ReadString('\n')
+		return event, data
+	}
+	event, data := next()
+	assert.Equal(t, "event:updated\n", event)
+	assert.Contains(t, data, `"type":"updated","id":3`)
+	event, data = next()
+	assert.Equal(t, "event:created\n", event)
+	assert.Contains(t, data, `"type":"created","id":4`)
+	assert.Contains(t, data, `"title":"Take out bins"`)
+}
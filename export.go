@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportPageSize is how many todos GET /todos/export reads from the store at
+// a time, so the full list is never held in memory
+const exportPageSize = 500
+
+// exportRow is the shape of one exported todo
+type exportRow struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	Done      bool      `json:"done"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// csvColumns maps each column a CSV export can hold to its value for a todo
+var csvColumns = map[string]func(Todo) string{
+	"id":         func(t Todo) string { return strconv.Itoa(t.ID) },
+	"title":      func(t Todo) string { return t.Title },
+	"done":       func(t Todo) string { return strconv.FormatBool(t.Done) },
+	"created_at": func(t Todo) string { return t.CreatedAt.UTC().Format(time.RFC3339) },
+}
+
+// defaultCSVColumns are the columns of a CSV export without a columns
+// parameter, in order
+var defaultCSVColumns = []string{"id", "title", "done", "created_at"}
+
+// parseCSVColumns parses the columns parameter of a CSV export, a
+// comma-separated list naming each wanted column once in the wanted order
+func parseCSVColumns(s string) ([]string, bool) {
+	columns := strings.Split(s, ",")
+	seen := make(map[string]bool, len(columns))
+	for i, column := range columns {
+		column = strings.TrimSpace(column)
+		if csvColumns[column] == nil || seen[column] {
+			return nil, false
+		}
+		seen[column] = true
+		columns[i] = column
+	}
+	return columns, true
+}
+
+// exportFormats maps each supported ?format= value to its content type
+var exportFormats = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"json": "application/json; charset=utf-8",
+}
+
+// exportTodos handles GET /todos/export. It streams every todo as CSV
+// (the default) or as a JSON array, one page of todos at a time. A CSV export
+// holds defaultCSVColumns unless the columns parameter picks others.
+func exportTodos(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	contentType, ok := exportFormats[format]
+	if !ok {
+		respondError(c, http.StatusBadRequest, "format must be csv or json")
+		return
+	}
+	columns := defaultCSVColumns
+	if s, ok := c.GetQuery("columns"); ok {
+		if format != "csv" {
+			respondError(c, http.StatusBadRequest, "columns only applies to csv exports")
+			return
+		}
+		if columns, ok = parseCSVColumns(s); !ok {
+			respondError(c, http.StatusBadRequest, "columns must list each of id, title, done and created_at at most once")
+			return
+		}
+	}
+
+	// Read the first page before writing anything, so a failing store still
+	// gets a proper error response
+	page, err := db.GetTodos(c.Request.Context(), TodoFilter{}, TodoOrder{}, exportPageSize, 0)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", "attachment; filename=todos."+format)
+	c.Status(http.StatusOK)
+
+	write := func(c *gin.Context, first []Todo) error {
+		return writeCSVRows(c, first, columns)
+	}
+	if format == "json" {
+		write = writeJSONRows
+	}
+	if err := write(c, page); err != nil {
+		slog.Default().LogAttrs(c.Request.Context(), slog.LevelError, "export failed",
+			slog.String("error", err.Error()),
+			slog.String("request_id", c.GetString(requestIDKey)),
+		)
+	}
+}
+
+// eachExportPage calls fn with first and then each following page of todos
+// until the store runs out
+func eachExportPage(c *gin.Context, first []Todo, fn func([]Todo) error) error {
+	page, offset := first, 0
+	for {
+		if err := fn(page); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		if len(page) < exportPageSize {
+			return nil
+		}
+		offset += len(page)
+		var err error
+		if page, err = db.GetTodos(c.Request.Context(), TodoFilter{}, TodoOrder{}, exportPageSize, offset); err != nil {
+			return err
+		}
+	}
+}
+
+// writeCSVRows writes a header row and then every todo, starting with first,
+// with the given columns
+func writeCSVRows(c *gin.Context, first []Todo, columns []string) error {
+	w := csv.NewWriter(c.Writer)
+	w.Write(columns)
+	record := make([]string, len(columns))
+	err := eachExportPage(c, first, func(page []Todo) error {
+		for _, todo := range page {
+			for i, column := range columns {
+				record[i] = csvColumns[column](todo)
+			}
+			w.Write(record)
+		}
+		w.Flush()
+		return w.Error()
+	})
+	if err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeJSONRows writes every todo, starting with first, as one JSON array
+func writeJSONRows(c *gin.Context, first []Todo) error {
+	c.Writer.WriteString("[")
+	n := 0
+	err := eachExportPage(c, first, func(page []Todo) error {
+		for _, todo := range page {
+			row, err := json.Marshal(exportRow{ID: todo.ID, Title: todo.Title, Done: todo.Done, CreatedAt: todo.CreatedAt})
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				c.Writer.WriteString(",")
+			}
+			if _, err := c.Writer.Write(row); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.Writer.WriteString("]")
+	return err
+}
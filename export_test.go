@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportTodos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+	created := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	t.Run("CSV", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Pay rent, then relax", Done: true})
+		for i := range testDB.todos {
+			testDB.todos[i].CreatedAt = created
+		}
+		req, _ := http.NewRequest("GET", "/todos/export?format=csv", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "attachment; filename=todos.csv", w.Header().Get("Content-Disposition"))
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"id", "title", "done", "created_at"},
+			{"1", "Learn Go", "false", "2024-01-01T09:00:00Z"},
+			{"2", "Set up CI/CD", "false", "2024-01-01T09:00:00Z"},
+			{"3", "Pay rent, then relax", "true", "2024-01-01T09:00:00Z"},
+		}, records)
+	})
+
+	t.Run("CSV Columns", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/export?columns=title,done,id", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		records, err := csv.NewReader(w.Body).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"title", "done", "id"},
+			{"Learn Go", "false", "1"},
+			{"Set up CI/CD", "false", "2"},
+		}, records)
+	})
+
+	t.Run("Invalid CSV Columns", func(t *testing.T) {
+		resetTodos()
+		for _, query := range []string{"columns=title,secret", "columns=id,id", "columns=", "format=json&columns=id"} {
+			req, _ := http.NewRequest("GET", "/todos/export?"+query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			assert.Empty(t, w.Header().Get("Content-Disposition"), query)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/export?format=json", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "attachment; filename=todos.json", w.Header().Get("Content-Disposition"))
+
+		var rows []exportRow
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rows))
+		assert.Equal(t, 2, len(rows))
+		assert.Equal(t, "Learn Go", rows[0].Title)
+	})
+
+	t.Run("Spans Several Pages", func(t *testing.T) {
+		resetTodos()
+		todos := make([]Todo, exportPageSize*2)
+		for i := range todos {
+			todos[i].Title = "Bulk"
+		}
+		testDB.CreateTodos(context.Background(), todos)
+		req, _ := http.NewRequest("GET", "/todos/export?format=json", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var rows []exportRow
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rows))
+		assert.Equal(t, exportPageSize*2+2, len(rows))
+		assert.Equal(t, exportPageSize*2+2, rows[len(rows)-1].ID)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		testDB = NewDatabase()
+		db = testDB
+		req, _ := http.NewRequest("GET", "/todos/export?format=json", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, "[]", w.Body.String())
+	})
+
+	t.Run("Unknown Format", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/export?format=xlsx", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipMinSize is the smallest response body worth compressing, in bytes
+const gzipMinSize = 1024
+
+// gzipMiddleware compresses response bodies of at least gzipMinSize bytes for
+// clients that accept gzip. Bodies that are already compressed, such as
+// images and archives, are sent as is.
+func gzipMiddleware(level int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if c.Request.Method == http.MethodHead || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		w := &gzipResponseWriter{ResponseWriter: c.Writer, level: level}
+		c.Writer = w
+		defer func() {
+			w.close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if q, err := strconv.ParseFloat(value, 64); strings.EqualFold(key, "q") && err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isCompressedType reports whether a content type is already compressed, so
+// gzipping it would only cost CPU
+func isCompressedType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"):
+		return true
+	}
+	switch mediaType {
+	case "application/gzip", "application/x-gzip", "application/zip",
+		"application/x-bzip2", "application/x-7z-compressed", "application/zstd",
+		"font/woff", "font/woff2":
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter holds back the body until it is known to reach
+// gzipMinSize, then either compresses it or writes it out unchanged
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	level int
+
+	buf bytes.Buffer
+	gz  *gzip.Writer
+	// passthrough is set once the body is known to be sent uncompressed
+	passthrough bool
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	switch {
+	case w.gz != nil:
+		return w.gz.Write(b)
+	case w.passthrough:
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf.Write(b)
+	if w.buf.Len() >= gzipMinSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends what has been written so far. A body flushed before reaching
+// gzipMinSize, such as an event stream, is sent uncompressed.
+func (w *gzipResponseWriter) Flush() {
+	if w.gz == nil && !w.passthrough {
+		w.startPassthrough()
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Hijack hands the connection over, e.g. for a WebSocket upgrade
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.passthrough = true
+	return w.ResponseWriter.Hijack()
+}
+
+// decide starts compressing the buffered body, unless its content type or an
+// existing Content-Encoding rules that out
+func (w *gzipResponseWriter) decide() error {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || isCompressedType(header.Get("Content-Type")) {
+		w.startPassthrough()
+		return nil
+	}
+
+	gz, err := gzip.NewWriterLevel(w.ResponseWriter, w.level)
+	if err != nil {
+		return err
+	}
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	w.gz = gz
+	_, err = w.gz.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// startPassthrough writes out the buffered body and sends the rest unchanged
+func (w *gzipResponseWriter) startPassthrough() {
+	w.passthrough = true
+	if w.buf.Len() > 0 {
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+// close finishes the response once the handler is done
+func (w *gzipResponseWriter) close() {
+	switch {
+	case w.gz != nil:
+		w.gz.Close()
+	case !w.passthrough:
+		w.startPassthrough()
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGzip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gzipMiddleware(gzip.BestSpeed))
+	r.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, strings.Repeat("todo ", 500)) })
+	r.GET("/small", func(c *gin.Context) { c.String(http.StatusOK, "todo") })
+	r.GET("/image", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", make([]byte, 4096)) })
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Compresses Large Responses", func(t *testing.T) {
+		w := get("/large", "br, gzip")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+
+		gz, err := gzip.NewReader(w.Body)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(gz)
+		assert.Equal(t, strings.Repeat("todo ", 500), string(body))
+	})
+
+	t.Run("Small Responses Are Not Compressed", func(t *testing.T) {
+		w := get("/small", "gzip")
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		assert.Equal(t, "todo", w.Body.String())
+	})
+
+	t.Run("Client Without Gzip", func(t *testing.T) {
+		for _, header := range []string{"", "br", "gzip;q=0"} {
+			w := get("/large", header)
+
+			assert.Empty(t, w.Header().Get("Content-Encoding"), header)
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"), header)
+			assert.Equal(t, strings.Repeat("todo ", 500), w.Body.String(), header)
+		}
+	})
+
+	t.Run("Compressed Types Are Skipped", func(t *testing.T) {
+		w := get("/image", "gzip")
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, 4096, w.Body.Len())
+	})
+
+	t.Run("Todo List", func(t *testing.T) {
+		resetTodos()
+		for i := 0; i < 20; i++ {
+			testDB.CreateTodo(context.Background(), &Todo{Title: "Another todo to fill the list"})
+		}
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		SetupRouter().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "22", w.Header().Get("X-Total-Count"))
+	})
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthzPath is where liveness probes check the service
+const healthzPath = "/healthz"
+
+// healthCheckTimeout bounds the database ping so a hung database cannot hang the probe
+const healthCheckTimeout = 2 * time.Second
+
+// getHealthz handles GET /healthz, the liveness probe
+func getHealthz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	if err := db.Ping(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
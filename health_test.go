@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// unreachableDatabase is a database whose ping always fails
+type unreachableDatabase struct {
+	DatabaseInterface
+}
+
+func (unreachableDatabase) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestGetHealthz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/healthz", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"status":"ok"}`, w.Body.String())
+	})
+
+	t.Run("Database Unreachable", func(t *testing.T) {
+		resetTodos()
+		db = unreachableDatabase{db}
+		req, _ := http.NewRequest("GET", "/healthz", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, `{"status":"unavailable"}`, w.Body.String())
+	})
+}
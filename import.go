@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// maxImportBytes caps the size of a POST /todos/import body
+const maxImportBytes = 5 << 20
+
+// importRowError reports why one imported row was skipped
+type importRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// importSummary is the POST /todos/import response body
+type importSummary struct {
+	Imported int              `json:"imported"`
+	Skipped  int              `json:"skipped"`
+	Errors   []importRowError `json:"errors"`
+}
+
+// importRow is a parsed row waiting to be validated, with the line it started
+// on. problem is set when the row could not even be parsed.
+type importRow struct {
+	line    int
+	todo    createTodoRequest
+	problem string
+}
+
+// importTodos handles POST /todos/import. The body is a CSV file with a
+// header row (text/csv) or a JSON array of todos (application/json). Invalid
+// rows are skipped and reported by line, unless strict=true is given, in
+// which case any invalid row aborts the import.
+func importTodos(c *gin.Context) {
+	strict, err := strconv.ParseBool(c.DefaultQuery("strict", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "strict must be true or false")
+		return
+	}
+
+	parse := parseJSONImport
+	switch c.ContentType() {
+	case "application/json":
+	case "text/csv", "application/csv":
+		parse = parseCSVImport
+	default:
+		respondError(c, http.StatusUnsupportedMediaType, "body must be text/csv or application/json")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondError(c, http.StatusRequestEntityTooLarge, "import exceeds the maximum of "+strconv.Itoa(maxImportBytes)+" bytes")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "could not read the request body")
+		return
+	}
+
+	rows, err := parse(body)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	summary := importSummary{Errors: []importRowError{}}
+	valid := make([]importRow, 0, len(rows))
+	for _, row := range rows {
+		if row.problem == "" {
+			if err := binding.Validator.ValidateStruct(row.todo); err != nil {
+				row.problem = fieldsMessage(bindErrorFields(err))
+			}
+		}
+		if row.problem != "" {
+			summary.Errors = append(summary.Errors, importRowError{Line: row.line, Error: row.problem})
+			continue
+		}
+		valid = append(valid, row)
+	}
+
+	// Rows are stored one at a time so that those the store rejects, such as
+	// ones naming an unknown category, are reported by line like any other
+	// bad row. In strict mode the transaction undoes the rows already stored.
+	ctx := c.Request.Context()
+	err = db.WithTx(ctx, func(tx TxInterface) error {
+		for _, row := range valid {
+			todo := row.todo.toTodo()
+			err := tx.CreateTodo(ctx, &todo)
+			if problem := importStoreProblem(err); problem != "" {
+				summary.Errors = append(summary.Errors, importRowError{Line: row.line, Error: problem})
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			summary.Imported++
+		}
+		if strict && len(summary.Errors) > 0 {
+			return errImportRejected
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRejected) {
+		respondInternalError(c, err)
+		return
+	}
+	slices.SortStableFunc(summary.Errors, func(a, b importRowError) int { return a.Line - b.Line })
+	summary.Skipped = len(summary.Errors)
+	if err != nil {
+		summary.Imported = 0
+		c.JSON(http.StatusBadRequest, summary)
+		return
+	}
+	if summary.Imported > 0 {
+		publishEvent(c, todoEvent{Type: eventCreated})
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// errImportRejected rolls back a strict import that has bad rows
+var errImportRejected = errors.New("import has invalid rows")
+
+// importStoreProblem describes why the store refused to create an imported
+// row, or returns "" if err is nil or not caused by the row
+func importStoreProblem(err error) string {
+	switch {
+	case errors.Is(err, ErrCategoryNotFound):
+		return "category_id does not name an existing category"
+	case errors.Is(err, ErrParentNotFound):
+		return "parent_id does not name an existing todo"
+	case errors.Is(err, ErrTodoExists):
+		return "a todo with this title already exists"
+	}
+	return ""
+}
+
+// parseCSVImport reads CSV rows into todos. The header row must name a title
+// column; done and due_date columns are optional and other columns, such as
+// the id and created_at written by GET /todos/export, are ignored.
+func parseCSVImport(body []byte) ([]importRow, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("CSV header row is malformed")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("CSV header row must include a title column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []importRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, errors.New("CSV is malformed: " + err.Error())
+		}
+		line, _ := r.FieldPos(0)
+
+		row := importRow{line: line, todo: createTodoRequest{Title: field(record, "title")}}
+		if done := field(record, "done"); done != "" {
+			if row.todo.Done, err = strconv.ParseBool(done); err != nil {
+				row.problem = "done must be true or false"
+			}
+		}
+		if due := field(record, "due_date"); due != "" && row.problem == "" {
+			if t, err := time.Parse(time.RFC3339, due); err != nil {
+				row.problem = "due_date must be in RFC 3339 format"
+			} else {
+				row.todo.DueDate = &t
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// parseJSONImport reads a JSON array of todos. Each row's line is the line
+// its object starts on.
+func parseJSONImport(body []byte) ([]importRow, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, errors.New("body must be a JSON array of todos")
+	}
+
+	var rows []importRow
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, errors.New("request body is not valid JSON")
+		}
+		end := int(dec.InputOffset())
+		line := 1 + bytes.Count(body[:end-len(raw)], []byte("\n"))
+
+		row := importRow{line: line}
+		if err := json.Unmarshal(raw, &row.todo); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &typeErr) && typeErr.Field != "":
+				row.problem = fieldsMessage(map[string]string{typeErr.Field: "must be a " + typeErr.Type.String()})
+			case errors.As(err, &typeErr):
+				row.problem = "row must be a JSON object"
+			default:
+				row.problem = malformedBodyMessage(err)
+			}
+		}
+		rows = append(rows, row)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, errors.New("request body is not valid JSON")
+	}
+	return rows, nil
+}
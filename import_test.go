@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportTodos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	importBody := func(w *httptest.ResponseRecorder) importSummary {
+		var summary importSummary
+		json.Unmarshal(w.Body.Bytes(), &summary)
+		return summary
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		resetTodos()
+		csv := "title,done,due_date\nBuy milk,false,\nFile taxes,true,2024-04-15T00:00:00Z\n"
+		req, _ := http.NewRequest("POST", "/todos/import", strings.NewReader(csv))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, importSummary{Imported: 2, Errors: []importRowError{}}, importBody(w))
+		assert.Equal(t, 4, len(testDB.todos))
+		assert.Equal(t, "File taxes", testDB.todos[3].Title)
+		assert.True(t, testDB.todos[3].Done)
+		assert.NotNil(t, testDB.todos[3].DueDate)
+	})
+
+	t.Run("Round Trips An Export", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/export?format=csv", nil)
+		exported := httptest.NewRecorder()
+		r.ServeHTTP(exported, req)
+
+		req, _ = http.NewRequest("POST", "/todos/import", exported.Body)
+		req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 2, importBody(w).Imported)
+		assert.Equal(t, "Learn Go", testDB.todos[2].Title)
+		assert.Equal(t, 3, testDB.todos[2].ID)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos/import", strings.NewReader(`[{"title": "Buy milk"}, {"title": "Walk dog", "tags": ["Home"]}]`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 2, importBody(w).Imported)
+		assert.Equal(t, []string{"home"}, testDB.todos[3].Tags)
+	})
+
+	t.Run("Reports Bad Rows By Line", func(t *testing.T) {
+		resetTodos()
+		csv := "title,done\nBuy milk,false\n,false\nWalk dog,maybe\n"
+		req, _ := http.NewRequest("POST", "/todos/import", strings.NewReader(csv))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, importSummary{Imported: 1, Skipped: 2, Errors: []importRowError{
+			{Line: 3, Error: "title is required"},
+			{Line: 4, Error: "done must be true or false"},
+		}}, importBody(w))
+		assert.Equal(t, 3, len(testDB.todos))
+	})
+
+	t.Run("Reports Bad JSON Rows By Line", func(t *testing.T) {
+		resetTodos()
+		body := "[\n  {\"title\": \"Buy milk\"},\n  {\"title\": \"   \"},\n  {\"title\": \"Walk dog\", \"done\": \"yes\"},\n  42\n]"
+		req, _ := http.NewRequest("POST", "/todos/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, importSummary{Imported: 1, Skipped: 3, Errors: []importRowError{
+			{Line: 3, Error: "title must not be blank"},
+			{Line: 4, Error: "done must be a bool"},
+			{Line: 5, Error: "row must be a JSON object"},
+		}}, importBody(w))
+	})
+
+	t.Run("Reports Rows The Store Rejects By Line", func(t *testing.T) {
+		resetTodos()
+		config.UniqueTitles = true
+		defer func() { config = defaultConfig() }()
+		body := "[\n  {\"title\": \"Buy milk\", \"category_id\": 7},\n  {\"title\": \"Walk dog\", \"parent_id\": 99},\n  {\"title\": \"Learn Go\"},\n  {\"title\": \"Water plants\"}\n]"
+		req, _ := http.NewRequest("POST", "/todos/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, importSummary{Imported: 1, Skipped: 3, Errors: []importRowError{
+			{Line: 2, Error: "category_id does not name an existing category"},
+			{Line: 3, Error: "parent_id does not name an existing todo"},
+			{Line: 4, Error: "a todo with this title already exists"},
+		}}, importBody(w))
+		assert.Equal(t, 3, len(testDB.todos))
+		assert.Equal(t, "Water plants", testDB.todos[2].Title)
+	})
+
+	t.Run("Strict Undoes Rows The Store Accepted", func(t *testing.T) {
+		resetTodos()
+		body := `[{"title": "Buy milk"}, {"title": "Walk dog", "category_id": 7}]`
+		req, _ := http.NewRequest("POST", "/todos/import?strict=true", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, importSummary{Skipped: 1, Errors: []importRowError{{Line: 1, Error: "category_id does not name an existing category"}}}, importBody(w))
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+
+	t.Run("Strict Aborts On Any Bad Row", func(t *testing.T) {
+		resetTodos()
+		csv := "title\nBuy milk\n\"\"\n"
+		req, _ := http.NewRequest("POST", "/todos/import?strict=true", strings.NewReader(csv))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, importSummary{Skipped: 1, Errors: []importRowError{{Line: 3, Error: "title is required"}}}, importBody(w))
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+
+	t.Run("Missing Title Column", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos/import", strings.NewReader("name,done\nBuy milk,false\n"))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "CSV header row must include a title column", errorBody(w).Message)
+	})
+
+	t.Run("Not A JSON Array", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos/import", strings.NewReader(`{"title": "Buy milk"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+
+	t.Run("Unsupported Content Type", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos/import", strings.NewReader("Buy milk"))
+		req.Header.Set("Content-Type", "text/plain")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("Too Large", func(t *testing.T) {
+		resetTodos()
+		body := "title\n" + strings.Repeat("Buy milk\n", maxImportBytes/9+1)
+		req, _ := http.NewRequest("POST", "/todos/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+}
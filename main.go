@@ -1,95 +1,1247 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Todo represents a to-do item
+// Todo represents a to-do item. It is also the v2 response shape, which
+// always sends the timestamp fields, as null when unset; v1 responses use
+// todoV1.
 type Todo struct {
-	ID    int    `json:"id"`
-	Title string `json:"title"`
-	Done  bool   `json:"done"`
+	XMLName xml.Name `json:"-" xml:"todo"`
+	ID      int      `json:"id" xml:"id"`
+	Title   string   `json:"title" xml:"title"`
+	Done    bool     `json:"done" xml:"done"`
+	// UserID is the owner of the todo; only they can see or change it
+	UserID string `json:"user_id,omitempty" xml:"user_id,omitempty"`
+	// Version starts at 1 and increments on every update
+	Version int `json:"version" xml:"version"`
+	// CreatedAt is set on insert; UpdatedAt starts equal to it and moves on every update
+	CreatedAt time.Time `json:"created_at" xml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" xml:"updated_at"`
+	// DueDate is optional and given in RFC3339
+	DueDate *time.Time `json:"due_date" xml:"due_date,omitempty"`
+	// ClaimedBy and LeaseExpiresAt are set while a worker holds the todo via POST /todos/claim
+	ClaimedBy      string     `json:"claimed_by,omitempty" xml:"claimed_by,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at" xml:"lease_expires_at,omitempty"`
+	// CategoryID is the category the todo belongs to, if any
+	CategoryID *int `json:"category_id,omitempty" xml:"category_id,omitempty"`
+	// Tags are lowercase labels without duplicates
+	Tags []string `json:"tags,omitempty" xml:"tags>tag,omitempty"`
+	// DeletedAt is set while the todo is soft-deleted
+	DeletedAt *time.Time `json:"deleted_at" xml:"deleted_at,omitempty"`
+	// Recurrence is daily, weekly or monthly for a todo that repeats, and
+	// empty otherwise. Completing a recurring todo creates its next occurrence.
+	Recurrence string `json:"recurrence,omitempty" xml:"recurrence,omitempty"`
+	// ParentID is the todo this one is a subtask of, if any
+	ParentID *int `json:"parent_id,omitempty" xml:"parent_id,omitempty"`
+	// Position orders the list; new todos go last and POST /todos/reorder
+	// rearranges them
+	Position int `json:"position" xml:"position"`
+	// IsOverdue is derived from DueDate and never stored. It is only set on
+	// responses to requests with ?include=is_overdue.
+	IsOverdue *bool `json:"is_overdue,omitempty" xml:"is_overdue,omitempty"`
 }
 
-// In-memory storage for todos
-var todos = []Todo{
-	{ID: 1, Title: "Learn Go", Done: false},
-	{ID: 2, Title: "Set up CI/CD", Done: false},
+// MarshalJSON encodes the todo, writing done as a string when config.DoneAsString is set
+func (t Todo) MarshalJSON() ([]byte, error) {
+	type plain Todo
+	if !config.DoneAsString {
+		return json.Marshal(plain(t))
+	}
+	return json.Marshal(struct {
+		plain
+		Done string `json:"done"`
+	}{plain(t), strconv.FormatBool(t.Done)})
+}
+
+// isOverdue reports whether the todo is still pending past its due date
+func (t Todo) isOverdue(now time.Time) bool {
+	return !t.Done && t.DueDate != nil && t.DueDate.Before(now)
+}
+
+// hasTag reports whether the todo carries tag, which must already be normalized
+func (t Todo) hasTag(tag string) bool {
+	return slices.Contains(t.Tags, tag)
+}
+
+// normalizeTags lowercases and trims tags, dropping blanks and duplicates
+// while keeping the first-seen order
+func normalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// maxTitleLength is the longest title accepted, in characters. It matches
+// the max=255 in the title binding tags.
+const maxTitleLength = 255
+
+// createTodoRequest is the POST /todos request body
+type createTodoRequest struct {
+	Title      string     `json:"title" binding:"required,notblank,max=255"`
+	Done       bool       `json:"done"`
+	DueDate    *time.Time `json:"due_date"`
+	CategoryID *int       `json:"category_id"`
+	Tags       []string   `json:"tags"`
+	Recurrence string     `json:"recurrence" binding:"omitempty,oneof=daily weekly monthly"`
+	ParentID   *int       `json:"parent_id"`
+}
+
+// toTodo returns the todo described by the request
+func (r createTodoRequest) toTodo() Todo {
+	return Todo{Title: r.Title, Done: r.Done, DueDate: r.DueDate, CategoryID: r.CategoryID, Tags: r.Tags, Recurrence: r.Recurrence, ParentID: r.ParentID}
+}
+
+// replaceTodoRequest is the PUT /todos/:id request body in replace mode
+type replaceTodoRequest struct {
+	Title   string     `json:"title" binding:"required,notblank,max=255"`
+	Done    *bool      `json:"done" binding:"required"`
+	DueDate *time.Time `json:"due_date"`
+	Tags    []string   `json:"tags"`
+	// Recurrence is cleared when omitted, like due_date
+	Recurrence string `json:"recurrence" binding:"omitempty,oneof=daily weekly monthly"`
+	// ParentID is cleared when omitted, like due_date
+	ParentID *int `json:"parent_id"`
+	// CategoryID is cleared when omitted, like due_date
+	CategoryID *int `json:"category_id"`
+	// Version, when set, must match the stored version or the update fails with 409
+	Version int `json:"version"`
+}
+
+// TodoPatch holds the fields of a partial update; nil fields are left unchanged
+type TodoPatch struct {
+	// Title is left alone when omitted or null, but an empty title is rejected
+	// like on create
+	Title *string `json:"title" binding:"omitempty,notblank,max=255"`
+	Done  *bool   `json:"done"`
+	// DueDate is changed when present; null removes it
+	DueDate nullableTime `json:"due_date"`
+	// Tags replaces every tag when present; an empty list removes them all
+	Tags []string `json:"tags"`
+	// Recurrence changes the rule when present; "none" stops the todo repeating
+	Recurrence *string `json:"recurrence" binding:"omitempty,oneof=none daily weekly monthly"`
+	// ParentID moves the todo under another when present; 0 makes it top-level
+	ParentID *int `json:"parent_id"`
+	// CategoryID moves the todo to another category when present; 0 takes it
+	// out of its category
+	CategoryID *int `json:"category_id"`
+	// Version, when set, must match the stored version or the update fails
+	// with 409. It changes nothing itself, and update-where rejects it.
+	Version int `json:"version"`
+}
+
+// nullableTime is a patch field that tells an omitted time apart from an
+// explicit null
+type nullableTime struct {
+	// Set is true when the field was present, even if null
+	Set  bool
+	Time *time.Time
+}
+
+// UnmarshalJSON records that the field was present. encoding/json calls it
+// for null too, as nullableTime is not a pointer.
+func (t *nullableTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	return json.Unmarshal(data, &t.Time)
+}
+
+// isEmpty reports whether the patch changes nothing
+func (p TodoPatch) isEmpty() bool {
+	return p.Title == nil && p.Done == nil && !p.DueDate.Set && p.Tags == nil && p.Recurrence == nil && p.ParentID == nil && p.CategoryID == nil
+}
+
+// parent returns the parent the patch moves todos under, or nil if it does not
+// give them one
+func (p TodoPatch) parent() *int {
+	if p.ParentID == nil || *p.ParentID == 0 {
+		return nil
+	}
+	return p.ParentID
+}
+
+// category returns the category the patch moves todos to, or nil if it does
+// not give them one
+func (p TodoPatch) category() *int {
+	if p.CategoryID == nil || *p.CategoryID == 0 {
+		return nil
+	}
+	return p.CategoryID
+}
+
+// apply copies the fields that were provided onto the todo
+func (p TodoPatch) apply(todo *Todo) {
+	if p.Title != nil {
+		todo.Title = *p.Title
+	}
+	if p.Done != nil {
+		todo.Done = *p.Done
+	}
+	if p.DueDate.Set {
+		todo.DueDate = p.DueDate.Time
+	}
+	if p.Tags != nil {
+		todo.Tags = normalizeTags(p.Tags)
+	}
+	if p.Recurrence != nil {
+		todo.Recurrence = *p.Recurrence
+		if todo.Recurrence == recurrenceNone {
+			todo.Recurrence = ""
+		}
+	}
+	if p.ParentID != nil {
+		todo.ParentID = p.parent()
+	}
+	if p.CategoryID != nil {
+		todo.CategoryID = p.category()
+	}
+}
+
+// updateWhereRequest is the POST /todos/update-where request body
+type updateWhereRequest struct {
+	Filter TodoFilter `json:"filter"`
+	Set    TodoPatch  `json:"set"`
+	// All must be set to apply the update with an empty filter
+	All bool `json:"all"`
+}
+
+// bulkDeleteRequest is the DELETE /todos request body
+type bulkDeleteRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// reorderRequest is the POST /todos/reorder request body
+type reorderRequest struct {
+	IDs []int `json:"ids" binding:"required,min=1"`
+}
+
+// claimRequest is the POST /todos/claim request body
+type claimRequest struct {
+	WorkerID string `json:"worker_id" binding:"required"`
+	// Count is how many todos to claim, 1 when omitted
+	Count int `json:"count"`
+	// LeaseSeconds is how long the claim lasts, defaultLeaseSeconds when omitted
+	LeaseSeconds int `json:"lease_seconds"`
+}
+
+// Default claim lease for POST /todos/claim
+const defaultLeaseSeconds = 300
+
+// todoSuggestion is the trimmed-down todo returned to typeahead clients
+type todoSuggestion struct {
+	ID    int    `json:"id" xml:"id"`
+	Title string `json:"title" xml:"title"`
+}
+
+// Default and maximum number of suggestions returned by GET /todos/suggest
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 50
+)
+
+// Maximum number of todos accepted by POST /todos/batch
+const maxBatchSize = 1000
+
+// Default and maximum page size for GET /todos
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// Storage used by the handlers, seeded with a couple of example todos
+var db DatabaseInterface = NewDatabase(
+	Todo{ID: 1, Title: "Learn Go", Done: false},
+	Todo{ID: 2, Title: "Set up CI/CD", Done: false},
+)
+
+// todoPage is the GET /todos response body when paging with a cursor
+type todoPage struct {
+	XMLName xml.Name `json:"-" xml:"todo_page"`
+	Todos   []Todo   `json:"todos" xml:"todos>todo"`
+	// NextCursor fetches the following page, or is null on the last page
+	NextCursor *string `json:"next_cursor" xml:"next_cursor,omitempty"`
 }
 
-// getTodos handles GET /todos
+// todoListMediaType is the Accept value that asks GET /todos for a todoList
+const todoListMediaType = "application/vnd.todo.v2+json"
+
+// todoList is the GET /todos response body when the client opts into the
+// envelope with envelope=true or Accept: application/vnd.todo.v2+json
+type todoList struct {
+	XMLName xml.Name `json:"-" xml:"todo_list"`
+	Data    []Todo   `json:"data" xml:"data>todo"`
+	Total   int      `json:"total" xml:"total"`
+	Limit   int      `json:"limit" xml:"limit"`
+	Offset  int      `json:"offset" xml:"offset"`
+}
+
+// getTodos handles GET /todos. Given a cursor parameter it pages by ID
+// instead of by offset and wraps the page in a todoPage. Otherwise it returns
+// a bare array unless the client asks for a todoList. It answers 304 when no
+// todo has changed since If-Modified-Since, and in XML when the client
+// accepts application/xml.
 func getTodos(c *gin.Context) {
-	c.JSON(http.StatusOK, todos)
+	listTodos(c, false)
+}
+
+// getTodosV2 handles GET /v2/todos. It is getTodos, except that offset pages
+// are always wrapped in a todoList.
+func getTodosV2(c *gin.Context) {
+	listTodos(c, true)
+}
+
+// listTodos lists todos for getTodos and getTodosV2, wrapping offset pages in
+// a todoList when alwaysEnvelope is set or the client asks for one
+func listTodos(c *gin.Context, alwaysEnvelope bool) {
+	// limit is capped below, where asking for too many todos is answered
+	// according to config.ListOverflow
+	limit, offset, ok := parsePageParams(c)
+	if !ok {
+		return
+	}
+
+	order, ok := parseSort(c)
+	if !ok {
+		return
+	}
+
+	filter := TodoFilter{Query: c.Query("q"), Tag: c.Query("tag")}
+	if s, ok := c.GetQuery("done"); ok {
+		done, err := strconv.ParseBool(s)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "done must be true or false")
+			return
+		}
+		filter.Done = &done
+	}
+	if s, ok := c.GetQuery("overdue"); ok {
+		overdue, err := strconv.ParseBool(s)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "overdue must be true or false")
+			return
+		}
+		filter.Overdue = &overdue
+	}
+	if s, ok := c.GetQuery("include_deleted"); ok {
+		includeDeleted, err := strconv.ParseBool(s)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "include_deleted must be true or false")
+			return
+		}
+		filter.IncludeDeleted = includeDeleted
+	}
+	envelope, err := strconv.ParseBool(c.DefaultQuery("envelope", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "envelope must be true or false")
+		return
+	}
+	if filter.CreatedAfter, err = queryTime(c, "created_after"); err != nil {
+		respondError(c, http.StatusBadRequest, "created_after must be an RFC 3339 timestamp")
+		return
+	}
+	if filter.CreatedBefore, err = queryTime(c, "created_before"); err != nil {
+		respondError(c, http.StatusBadRequest, "created_before must be an RFC 3339 timestamp")
+		return
+	}
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && filter.CreatedAfter.After(*filter.CreatedBefore) {
+		respondError(c, http.StatusBadRequest, "created_after must not be later than created_before")
+		return
+	}
+	varyOnAccept(c)
+	envelopeType := strings.Contains(c.GetHeader("Accept"), todoListMediaType)
+	cursor, useCursor := c.GetQuery("cursor")
+	if useCursor {
+		if _, ok := c.GetQuery("offset"); ok || order != (TodoOrder{}) {
+			respondError(c, http.StatusBadRequest, "cursor cannot be combined with offset or sort")
+			return
+		}
+	}
+	afterID, err := decodeCursor(cursor)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "cursor is invalid")
+		return
+	}
+
+	lastModified, err := db.LastModified(c.Request.Context())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+		if notModifiedSince(c.GetHeader("If-Modified-Since"), lastModified) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	if all, err := db.CountAllTodos(c.Request.Context()); err == nil {
+		todosTotal.Set(float64(all))
+	}
+
+	requested := limit
+	maxRows := maxPageLimit
+	if config.ListMaxRows > 0 {
+		maxRows = min(config.ListMaxRows, maxPageLimit)
+	}
+	limit = min(limit, maxRows)
+	// overflowed handles asking for more than config.ListMaxRows todos when
+	// more than that many exist, and reports whether it answered the request
+	overflowed := func(total int) bool {
+		if config.ListMaxRows <= 0 || requested <= maxRows || total-offset <= maxRows {
+			return false
+		}
+		if config.ListOverflow == listOverflowReject {
+			respondError(c, http.StatusBadRequest, "too many todos to list at once (more than "+strconv.Itoa(maxRows)+"), use a smaller limit")
+			return true
+		}
+		c.Header("Warning", `199 - "result truncated to `+strconv.Itoa(maxRows)+` todos"`)
+		return false
+	}
+
+	if useCursor {
+		total, err := db.TotalTodos(c.Request.Context(), filter)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if overflowed(total) {
+			return
+		}
+		filter.AfterID = afterID
+		page, err := db.GetTodos(c.Request.Context(), filter, TodoOrder{Field: "id"}, limit+1, 0)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		body := todoPage{Todos: page}
+		if len(page) > limit {
+			body.Todos = page[:limit]
+			next := encodeCursor(page[limit-1].ID)
+			body.NextCursor = &next
+		}
+		c.Header("X-Total-Count", strconv.Itoa(total))
+		respondNegotiated(c, http.StatusOK, body)
+		return
+	}
+
+	page, total, err := db.GetTodosPage(c.Request.Context(), filter, order, limit, offset)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if overflowed(total) {
+		return
+	}
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	if envelope || envelopeType || alwaysEnvelope {
+		if envelopeType {
+			c.Header("Content-Type", todoListMediaType)
+		}
+		respondNegotiated(c, http.StatusOK, todoList{Data: page, Total: total, Limit: limit, Offset: offset})
+		return
+	}
+	respondTodos(c, http.StatusOK, page)
+}
+
+// getTodo handles GET /todos/:id. It answers 304 when If-None-Match holds the
+// todo's current ETag, and in XML when the client accepts application/xml.
+func getTodo(c *gin.Context) {
+	id := c.Param("id")
+
+	todo, err := db.GetTodoByID(c.Request.Context(), toInt(id))
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Todo not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	etag := todoETag(*todo)
+	c.Header("ETag", etag)
+	varyOnAccept(c)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	respondNegotiated(c, http.StatusOK, todo)
+}
+
+// todoStats is the GET /todos/stats response body
+type todoStats struct {
+	XMLName xml.Name `json:"-" xml:"todo_stats"`
+	Total   int      `json:"total" xml:"total"`
+	Done    int      `json:"done" xml:"done"`
+	Pending int      `json:"pending" xml:"pending"`
+}
+
+// getTodoStats handles GET /todos/stats
+func getTodoStats(c *gin.Context) {
+	total, done, err := db.CountTodos(c.Request.Context())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respondNegotiated(c, http.StatusOK, todoStats{Total: total, Done: done, Pending: total - done})
+}
+
+// defaultDueSoonWindow is how far ahead GET /todos/due-soon looks without a within parameter
+const defaultDueSoonWindow = 24 * time.Hour
+
+// getTodosDueSoon handles GET /todos/due-soon. within is a Go duration such
+// as 24h or 90m.
+func getTodosDueSoon(c *gin.Context) {
+	within := defaultDueSoonWindow
+	if s, ok := c.GetQuery("within"); ok {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			respondError(c, http.StatusBadRequest, "within must be a positive duration such as 24h")
+			return
+		}
+		within = d
+	}
+
+	todos, err := db.GetTodosDueWithin(c.Request.Context(), within)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respondTodos(c, http.StatusOK, todos)
+}
+
+// defaultStaleDays is how old GET /todos/stale expects a todo to be without a days parameter
+const defaultStaleDays = 30
+
+// getStaleTodos handles GET /todos/stale, listing the pending todos created
+// more than days days ago, oldest first. days is capped at config.StaleMaxDays.
+func getStaleTodos(c *gin.Context) {
+	days := defaultStaleDays
+	if s, ok := c.GetQuery("days"); ok {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			respondError(c, http.StatusBadRequest, "days must be a positive integer")
+			return
+		}
+		days = n
+	}
+	days = min(days, config.StaleMaxDays)
+
+	todos, err := db.GetStaleTodos(c.Request.Context(), timeNow().AddDate(0, 0, -days))
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respondTodos(c, http.StatusOK, todos)
+}
+
+// getTodoSuggestions handles GET /todos/suggest
+func getTodoSuggestions(c *gin.Context) {
+	prefix := c.Query("prefix")
+	if prefix == "" {
+		respondError(c, http.StatusBadRequest, "prefix is required")
+		return
+	}
+
+	limit := defaultSuggestLimit
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxSuggestLimit {
+			respondError(c, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxSuggestLimit))
+			return
+		}
+		limit = n
+	}
+
+	matches, err := db.GetTodosByTitlePrefix(c.Request.Context(), prefix, limit)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	suggestions := make([]todoSuggestion, 0, len(matches))
+	for _, todo := range matches {
+		suggestions = append(suggestions, todoSuggestion{ID: todo.ID, Title: todo.Title})
+	}
+	respondSuggestions(c, http.StatusOK, suggestions)
 }
 
 // postTodo handles POST /todos
 func postTodo(c *gin.Context) {
-	var newTodo Todo
-	if err := c.ShouldBindJSON(&newTodo); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var body createTodoRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	newTodo := body.toTodo()
+	err := db.CreateTodo(c.Request.Context(), &newTodo)
+	if errors.Is(err, ErrCategoryNotFound) {
+		respondError(c, http.StatusBadRequest, "category_id does not name an existing category")
 		return
 	}
-	// Assign an ID
-	newTodo.ID = len(todos) + 1
-	todos = append(todos, newTodo)
-	c.JSON(http.StatusCreated, newTodo)
+	if errors.Is(err, ErrTodoExists) {
+		respondError(c, http.StatusConflict, "a todo with this title already exists")
+		return
+	}
+	if respondParentError(c, err) {
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	publishEvent(c, todoEvent{Type: eventCreated, ID: newTodo.ID, Todo: &newTodo})
+	respondNegotiated(c, http.StatusCreated, newTodo)
+}
+
+// postTodosBatch handles POST /todos/batch
+func postTodosBatch(c *gin.Context) {
+	var body []createTodoRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if len(body) == 0 {
+		respondError(c, http.StatusBadRequest, "batch is empty")
+		return
+	}
+	if len(body) > maxBatchSize {
+		respondError(c, http.StatusBadRequest, "batch exceeds the maximum of "+strconv.Itoa(maxBatchSize)+" todos")
+		return
+	}
+
+	newTodos := make([]Todo, 0, len(body))
+	for _, item := range body {
+		newTodos = append(newTodos, item.toTodo())
+	}
+
+	created, err := db.CreateTodos(c.Request.Context(), newTodos)
+	if errors.Is(err, ErrCategoryNotFound) {
+		respondError(c, http.StatusBadRequest, "category_id does not name an existing category")
+		return
+	}
+	if errors.Is(err, ErrTodoExists) {
+		respondError(c, http.StatusConflict, "a todo with this title already exists")
+		return
+	}
+	if respondParentError(c, err) {
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	publishEvent(c, todoEvent{Type: eventCreated})
+	respondTodos(c, http.StatusCreated, created)
 }
 
-// putTodo handles PUT /todos/:id
+// putTodo handles PUT /todos/:id. When If-Match is sent, the update only
+// goes ahead if it holds the todo's current ETag and the todo has not changed
+// again by the time it is written.
 func putTodo(c *gin.Context) {
 	id := c.Param("id")
-	var updatedTodo Todo
-	if err := c.ShouldBindJSON(&updatedTodo); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// matchedVersion is the version If-Match was checked against, which the
+	// write then expects unless the body names a version itself
+	matchedVersion := 0
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		current, err := db.GetTodoByID(c.Request.Context(), toInt(id))
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Todo not found")
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if !etagMatches(ifMatch, todoETag(*current)) {
+			respondError(c, http.StatusPreconditionFailed, "todo has changed since it was fetched")
+			return
+		}
+		matchedVersion = current.Version
+	}
+
+	var todo, next *Todo
+	var err error
+	if config.PutMode == putModeReplace {
+		// A full replace must not silently zero fields the client left out,
+		// so title and done are required. Optional fields such as due_date
+		// are cleared when omitted.
+		var body replaceTodoRequest
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if body.Version == 0 {
+			body.Version = matchedVersion
+		}
+		todo, next, err = db.UpdateTodo(c.Request.Context(), toInt(id), body.Version, Todo{Title: body.Title, Done: *body.Done, DueDate: body.DueDate, Tags: body.Tags, Recurrence: body.Recurrence, ParentID: body.ParentID, CategoryID: body.CategoryID})
+	} else {
+		var updatedTodo TodoPatch
+		if err := c.ShouldBindJSON(&updatedTodo); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if updatedTodo.Version == 0 {
+			updatedTodo.Version = matchedVersion
+		}
+		todo, next, err = db.PatchTodo(c.Request.Context(), toInt(id), updatedTodo)
+	}
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Todo not found")
+		return
+	}
+	if errors.Is(err, ErrVersionConflict) {
+		respondError(c, http.StatusConflict, "todo was updated by someone else; fetch it and retry")
+		return
+	}
+	if errors.Is(err, ErrCategoryNotFound) {
+		respondError(c, http.StatusBadRequest, "category_id does not name an existing category")
+		return
+	}
+	if errors.Is(err, ErrTodoExists) {
+		respondError(c, http.StatusConflict, "a todo with this title already exists")
+		return
+	}
+	if respondParentError(c, err) {
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	publishEvent(c, todoEvent{Type: eventUpdated, ID: todo.ID, Todo: todo})
+	if next != nil {
+		publishEvent(c, todoEvent{Type: eventCreated, ID: next.ID, Todo: next})
+	}
+	c.Header("ETag", todoETag(*todo))
+	respondNegotiated(c, http.StatusOK, todo)
+}
+
+// patchTodo handles PATCH /todos/:id
+func patchTodo(c *gin.Context) {
+	id := c.Param("id")
+	var fields TodoPatch
+	if err := c.ShouldBindJSON(&fields); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if fields.isEmpty() {
+		respondError(c, http.StatusBadRequest, "body contains no updatable fields")
+		return
+	}
+
+	todo, next, err := db.PatchTodo(c.Request.Context(), toInt(id), fields)
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Todo not found")
+		return
+	}
+	if errors.Is(err, ErrVersionConflict) {
+		respondError(c, http.StatusConflict, "todo was updated by someone else; fetch it and retry")
+		return
+	}
+	if errors.Is(err, ErrCategoryNotFound) {
+		respondError(c, http.StatusBadRequest, "category_id does not name an existing category")
+		return
+	}
+	if errors.Is(err, ErrTodoExists) {
+		respondError(c, http.StatusConflict, "a todo with this title already exists")
+		return
+	}
+	if respondParentError(c, err) {
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	publishEvent(c, todoEvent{Type: eventUpdated, ID: todo.ID, Todo: todo})
+	if next != nil {
+		publishEvent(c, todoEvent{Type: eventCreated, ID: next.ID, Todo: next})
+	}
+	respondNegotiated(c, http.StatusOK, todo)
+}
+
+// updateTodosWhere handles POST /todos/update-where
+func updateTodosWhere(c *gin.Context) {
+	var body updateWhereRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if body.Filter.isEmpty() && !body.All {
+		respondError(c, http.StatusBadRequest, "filter is empty; set all to true to update every todo")
+		return
+	}
+	if body.Set.isEmpty() {
+		respondError(c, http.StatusBadRequest, "set must contain at least one field")
+		return
+	}
+	if body.Set.Version != 0 {
+		respondError(c, http.StatusBadRequest, "set cannot contain version")
 		return
 	}
 
-	// Find and update the todo
-	var found bool
-	for i, todo := range todos {
-		if todo.ID == toInt(id) {
-			todos[i].Title = updatedTodo.Title
-			todos[i].Done = updatedTodo.Done
-			found = true
-			c.JSON(http.StatusOK, todos[i])
+	n, err := db.UpdateTodosWhere(c.Request.Context(), body.Filter, body.Set)
+	if errors.Is(err, ErrCategoryNotFound) {
+		respondError(c, http.StatusBadRequest, "category_id does not name an existing category")
+		return
+	}
+	if errors.Is(err, ErrTodoExists) {
+		respondError(c, http.StatusConflict, "a todo with this title already exists")
+		return
+	}
+	if respondParentError(c, err) {
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if n > 0 {
+		publishEvent(c, todoEvent{Type: eventUpdated})
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": n})
+}
+
+// setAllDone returns the handler for POST /todos/complete-all (done true) and
+// POST /todos/uncomplete-all (done false)
+func setAllDone(done bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		n, err := db.SetAllDone(c.Request.Context(), done)
+		if errors.Is(err, ErrTodoExists) {
+			respondError(c, http.StatusConflict, "a todo with this title already exists")
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
 			return
 		}
+		if n > 0 {
+			publishEvent(c, todoEvent{Type: eventUpdated})
+		}
+		c.JSON(http.StatusOK, gin.H{"updated": n})
+	}
+}
+
+// reorderTodos handles POST /todos/reorder
+func reorderTodos(c *gin.Context) {
+	var body reorderRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	seen := make(map[int]bool, len(body.IDs))
+	for _, id := range body.IDs {
+		if seen[id] {
+			respondError(c, http.StatusBadRequest, "ids must not repeat, but "+strconv.Itoa(id)+" does")
+			return
+		}
+		seen[id] = true
+	}
+
+	err := db.ReorderTodos(c.Request.Context(), body.IDs)
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, http.StatusBadRequest, "ids must all name existing todos")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
 	}
+	publishEvent(c, todoEvent{Type: eventUpdated})
+	c.JSON(http.StatusOK, gin.H{"reordered": len(body.IDs)})
+}
 
-	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+// claimTodos handles POST /todos/claim
+func claimTodos(c *gin.Context) {
+	var body claimRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if body.Count == 0 {
+		body.Count = 1
 	}
+	if body.LeaseSeconds == 0 {
+		body.LeaseSeconds = defaultLeaseSeconds
+	}
+	if body.Count < 0 || body.Count > maxPageLimit {
+		respondError(c, http.StatusBadRequest, "count must be between 1 and "+strconv.Itoa(maxPageLimit))
+		return
+	}
+	if body.LeaseSeconds < 0 {
+		respondError(c, http.StatusBadRequest, "lease_seconds must be positive")
+		return
+	}
+
+	claimed, err := db.ClaimTodos(c.Request.Context(), body.WorkerID, body.Count, time.Duration(body.LeaseSeconds)*time.Second)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if len(claimed) > 0 {
+		publishEvent(c, todoEvent{Type: eventUpdated})
+	}
+	respondTodos(c, http.StatusOK, claimed)
 }
 
-// deleteTodo handles DELETE /todos/:id
+// deleteTodo handles DELETE /todos/:id. The todo is soft-deleted unless
+// hard=true is given. A todo with subtasks is only deleted, along with them,
+// when cascade=true is given.
 func deleteTodo(c *gin.Context) {
 	id := c.Param("id")
+	hard, err := strconv.ParseBool(c.DefaultQuery("hard", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "hard must be true or false")
+		return
+	}
+	cascade, err := strconv.ParseBool(c.DefaultQuery("cascade", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "cascade must be true or false")
+		return
+	}
 
-	// Find and remove the todo
-	for i, todo := range todos {
-		if todo.ID == toInt(id) {
-			todos = append(todos[:i], todos[i+1:]...)
-			c.JSON(http.StatusOK, gin.H{"message": "Todo deleted"})
-			return
-		}
+	switch {
+	case cascade:
+		_, err = db.DeleteTodoCascade(c.Request.Context(), toInt(id), hard)
+	case hard:
+		err = db.HardDeleteTodo(c.Request.Context(), toInt(id))
+	default:
+		err = db.DeleteTodo(c.Request.Context(), toInt(id))
+	}
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Todo not found")
+		return
+	}
+	if errors.Is(err, ErrHasSubtasks) {
+		respondError(c, http.StatusConflict, "todo has subtasks; delete them first or pass cascade=true")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if cascade {
+		publishEvent(c, todoEvent{Type: eventDeleted})
+	} else {
+		publishEvent(c, todoEvent{Type: eventDeleted, ID: toInt(id)})
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Todo deleted"})
+}
+
+// restoreTodo handles POST /todos/:id/restore
+func restoreTodo(c *gin.Context) {
+	id := c.Param("id")
+
+	err := db.RestoreTodo(c.Request.Context(), toInt(id))
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Todo not found")
+		return
+	}
+	if errors.Is(err, ErrTodoExists) {
+		respondError(c, http.StatusConflict, "a todo with this title already exists")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	publishEvent(c, todoEvent{Type: eventUpdated, ID: toInt(id)})
+	c.JSON(http.StatusOK, gin.H{"message": "Todo restored"})
+}
+
+// duplicateTodo handles POST /todos/:id/duplicate
+func duplicateTodo(c *gin.Context) {
+	id := c.Param("id")
+
+	todo, err := db.DuplicateTodo(c.Request.Context(), toInt(id))
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Todo not found")
+		return
+	}
+	if errors.Is(err, ErrTodoExists) {
+		respondError(c, http.StatusConflict, "a todo with this title already exists")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	publishEvent(c, todoEvent{Type: eventCreated, ID: todo.ID, Todo: todo})
+	respondNegotiated(c, http.StatusCreated, todo)
+}
+
+// deleteTodos handles DELETE /todos
+func deleteTodos(c *gin.Context) {
+	var body bulkDeleteRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if len(body.IDs) == 0 {
+		respondError(c, http.StatusBadRequest, "ids must not be empty")
+		return
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+	n, err := db.DeleteTodos(c.Request.Context(), body.IDs)
+	if errors.Is(err, ErrHasSubtasks) {
+		respondError(c, http.StatusConflict, "a todo in ids has subtasks; delete them too or use DELETE /todos/:id?cascade=true")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if n > 0 {
+		publishEvent(c, todoEvent{Type: eventDeleted})
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": n})
 }
 
-// SetupRouter initializes and returns the Gin router with all routes
+// clearCompleted handles POST /todos/clear-completed
+func clearCompleted(c *gin.Context) {
+	n, err := db.ClearCompleted(c.Request.Context())
+	if errors.Is(err, ErrHasSubtasks) {
+		respondError(c, http.StatusConflict, "a completed todo has pending subtasks; finish or delete them first")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if n > 0 {
+		publishEvent(c, todoEvent{Type: eventDeleted})
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": n})
+}
+
+// SetupRouter initializes and returns the Gin router with all routes. The API
+// sits under config.BasePath, as do /healthz and /metrics when
+// config.OpsUnderBasePath is set.
 func SetupRouter() *gin.Engine {
-	r := gin.Default()
-	r.GET("/todos", getTodos)
-	r.POST("/todos", postTodo)
-	r.PUT("/todos/:id", putTodo)
-	r.DELETE("/todos/:id", deleteTodo)
+	base := config.BasePath
+	opsBase := ""
+	if config.OpsUnderBasePath {
+		opsBase = base
+	}
+
+	r := gin.New()
+	// gin believes X-Forwarded-For from anyone by default, which would let a
+	// client pick its own rate limit bucket and logged IP
+	if err := r.SetTrustedProxies(config.TrustedProxies); err != nil {
+		slog.Warn("ignoring TRUSTED_PROXIES", "error", err)
+		r.SetTrustedProxies(nil)
+	}
+	r.Use(requestLogger(slog.Default()), gin.Recovery(), metricsMiddleware(opsBase), corsMiddleware(config.CORSOrigins), userMiddleware, requestTimeoutMiddleware(config.RequestTimeout, base), gzipMiddleware(config.GzipLevel), bodyLimitMiddleware(config.MaxBodyBytes, base))
+	if config.RateLimitRPS > 0 {
+		r.Use(rateLimitMiddleware(config.RateLimitRPS, config.RateLimitBurst, opsBase))
+	}
+
+	ops := r.Group(opsBase)
+	ops.GET(healthzPath, getHealthz)
+	ops.GET(metricsPath, getMetrics)
+	if config.Debug {
+		ops.GET(debugConfigPath, getDebugConfig)
+	}
+
+	api := r.Group(base)
+	// Unversioned routes stay as they are for existing clients, mirroring /v1
+	registerV1Routes(api)
+	registerV1Routes(api.Group("/v1"))
+	registerV2Routes(api.Group("/v2"))
+
+	registerOptionsRoutes(r)
 	return r
 }
 
+// registerV1Routes registers the v1 API on g. v1 is frozen: it shares its
+// handlers with v2, but todos in its responses and events are mapped to
+// todoV1, so changes to Todo do not reach v1 clients.
+func registerV1Routes(g *gin.RouterGroup) {
+	registerTodoRoutes(g.Group("", withAPIVersion(apiV1), parseInclude), getTodos)
+}
+
+// registerV2Routes registers the v2 API on g. It differs from v1 in always
+// wrapping GET /todos in a todoList and in rendering todos as Todo, with
+// every timestamp field present.
+func registerV2Routes(g *gin.RouterGroup) {
+	registerTodoRoutes(g.Group("", withAPIVersion(apiV2), parseInclude), getTodosV2)
+}
+
+// registerTodoRoutes registers the routes shared by every API version on g,
+// listing todos with list
+func registerTodoRoutes(g *gin.RouterGroup, list gin.HandlerFunc) {
+	g.GET("/ws", serveWebSocket)
+	g.GET("/todos", list)
+	g.GET("/todos/suggest", getTodoSuggestions)
+	g.GET("/todos/stats", getTodoStats)
+	g.GET("/todos/due-soon", getTodosDueSoon)
+	g.GET("/todos/stale", getStaleTodos)
+	g.GET("/todos/export", exportTodos)
+	g.GET("/todos/events", streamTodoEvents)
+	g.GET("/todos/:id", getTodo)
+	g.POST("/todos", postTodo)
+	g.POST("/todos/batch", postTodosBatch)
+	g.POST("/todos/import", importTodos)
+	g.POST("/todos/update-where", updateTodosWhere)
+	g.POST("/todos/claim", claimTodos)
+	g.POST("/todos/reorder", reorderTodos)
+	g.POST("/todos/complete-all", setAllDone(true))
+	g.POST("/todos/uncomplete-all", setAllDone(false))
+	g.POST("/todos/clear-completed", clearCompleted)
+	g.POST("/todos/:id/restore", restoreTodo)
+	g.POST("/todos/:id/duplicate", duplicateTodo)
+	g.GET("/todos/:id/subtasks", getSubtasks)
+	g.PUT("/todos/:id", putTodo)
+	g.PATCH("/todos/:id", patchTodo)
+	g.DELETE("/todos", deleteTodos)
+	g.DELETE("/todos/:id", deleteTodo)
+
+	g.GET("/categories", getCategories)
+	g.POST("/categories", postCategory)
+	g.GET("/categories/:id/todos", getCategoryTodos)
+	g.DELETE("/categories/:id", deleteCategory)
+
+	compat := g.Group("/compat")
+	compat.GET("/todos", getLegacyTodos)
+	compat.POST("/todos", postLegacyTodo)
+	compat.PUT("/todos/:id", putLegacyTodo)
+
+	admin := g.Group("/admin", requireAdmin)
+	admin.GET("/title-stats", getTitleStats)
+}
+
+// registerOptionsRoutes answers OPTIONS on every registered path with an Allow
+// header listing the methods that path supports. Call it after all other routes.
+func registerOptionsRoutes(r *gin.Engine) {
+	methods := map[string][]string{}
+	for _, route := range r.Routes() {
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+	for path, allowed := range methods {
+		allowed = append(allowed, http.MethodOptions)
+		sort.Strings(allowed)
+		allow := strings.Join(allowed, ", ")
+		r.OPTIONS(path, func(c *gin.Context) {
+			c.Header("Allow", allow)
+			c.Status(http.StatusNoContent)
+		})
+	}
+}
+
+// parsePagination reads the limit and offset query parameters, capping limit at
+// maxPageLimit. On invalid input it writes a 400 response and returns ok=false.
+func parsePagination(c *gin.Context) (limit, offset int, ok bool) {
+	limit, offset, ok = parsePageParams(c)
+	return min(limit, maxPageLimit), offset, ok
+}
+
+// parsePageParams is parsePagination without the cap on limit
+func parsePageParams(c *gin.Context) (limit, offset int, ok bool) {
+	limit, err := queryInt(c, "limit", defaultPageLimit)
+	if err != nil || limit < 1 {
+		respondError(c, http.StatusBadRequest, "limit must be a positive integer")
+		return 0, 0, false
+	}
+	offset, err = queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		respondError(c, http.StatusBadRequest, "offset must be a non-negative integer")
+		return 0, 0, false
+	}
+	return limit, offset, true
+}
+
+// parseSort reads the sort query parameter, a field name optionally prefixed
+// with - for descending order. On an unknown field it writes a 400 response
+// and returns ok=false.
+func parseSort(c *gin.Context) (order TodoOrder, ok bool) {
+	value := c.Query("sort")
+	if value == "" {
+		return TodoOrder{}, true
+	}
+	field, desc := strings.CutPrefix(value, "-")
+	if _, known := sortFields[field]; !known {
+		fields := make([]string, 0, len(sortFields))
+		for name := range sortFields {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+		respondError(c, http.StatusBadRequest, "sort must be one of "+strings.Join(fields, ", ")+", optionally prefixed with -")
+		return TodoOrder{}, false
+	}
+	return TodoOrder{Field: field, Desc: desc}, true
+}
+
+// encodeCursor returns the cursor for the page after the todo with the given ID
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+// decodeCursor returns the ID a cursor continues after. The empty cursor
+// starts from the beginning.
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id < 1 {
+		return 0, errors.New("cursor does not hold a todo ID")
+	}
+	return id, nil
+}
+
+// queryInt parses an integer query parameter, returning def when it is absent
+func queryInt(c *gin.Context, key string, def int) (int, error) {
+	s, ok := c.GetQuery(key)
+	if !ok {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// queryTime parses an RFC 3339 query parameter, returning nil when it is absent
+func queryTime(c *gin.Context, key string) (*time.Time, error) {
+	s, ok := c.GetQuery(key)
+	if !ok {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
 // Helper function to convert ID string to int
 func toInt(s string) int {
 	id, err := strconv.Atoi(s)
@@ -100,6 +1252,43 @@ func toInt(s string) int {
 }
 
 func main() {
-	r := SetupRouter()
-	r.Run(":8080")
+	config = loadConfig()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: config.LogLevel})))
+	for _, warning := range config.warnings {
+		slog.Warn(warning)
+	}
+	if config.SlowQueryThreshold > 0 {
+		db = newSlowQueryDatabase(db, config.SlowQueryThreshold, slog.Default())
+	}
+	if config.CacheSize > 0 {
+		db = newCachingDatabase(db, config.CacheSize, config.CacheTTL)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: config.Addr, Handler: SetupRouter()}
+	// Event streams and WebSockets never finish on their own, so end them
+	// when shutting down
+	srv.RegisterOnShutdown(events.close)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("listen: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Printf("shutting down, waiting up to %s for active requests", config.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+
+	// Only close storage once the server has stopped handing it requests
+	if err := db.Close(); err != nil {
+		log.Printf("closing database: %v", err)
+	}
 }
@@ -1,52 +1,2092 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
-func resetTodos() {
-	todos = []Todo{
+// testDB is the in-memory database installed by resetTodos
+var testDB *Database
+
+// resetTodos installs a fresh database holding the two seed todos plus any extras
+func resetTodos(extra ...Todo) {
+	testDB = NewDatabase(append([]Todo{
 		{ID: 1, Title: "Learn Go", Done: false},
 		{ID: 2, Title: "Set up CI/CD", Done: false},
-	}
+	}, extra...)...)
+	db = testDB
+}
+
+func TestGetTodos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 2, len(response))
+		assert.Equal(t, "Learn Go", response[0].Title)
+		assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("Include Is Overdue", func(t *testing.T) {
+		resetTodos()
+		past := time.Now().Add(-time.Hour)
+		testDB.todos[0].DueDate = &past
+		req, _ := http.NewRequest("GET", "/todos?envelope=true&include=is_overdue", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var list todoList
+		json.Unmarshal(w.Body.Bytes(), &list)
+		if assert.Len(t, list.Data, 2) && assert.NotNil(t, list.Data[0].IsOverdue) && assert.NotNil(t, list.Data[1].IsOverdue) {
+			assert.True(t, *list.Data[0].IsOverdue)
+			assert.False(t, *list.Data[1].IsOverdue)
+		}
+
+		req.Header.Set("Accept", "application/xml")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Contains(t, w.Body.String(), "<is_overdue>true</is_overdue>")
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs"})
+		req, _ := http.NewRequest("GET", "/todos?limit=1&offset=1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 1, len(response))
+		assert.Equal(t, 2, response[0].ID)
+	})
+
+	t.Run("Offset Past End", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos?offset=10", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]", w.Body.String())
+		assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("Filter By Done", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs", Done: true})
+		for query, want := range map[string][]int{"done=true": {3}, "done=false": {1, 2}, "": {1, 2, 3}} {
+			req, _ := http.NewRequest("GET", "/todos?"+query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response []Todo
+			json.Unmarshal(w.Body.Bytes(), &response)
+
+			assert.Equal(t, want, todoIDs(response), query)
+			assert.Equal(t, strconv.Itoa(len(want)), w.Header().Get("X-Total-Count"), query)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Cut costs by 50%", Done: true}, Todo{ID: 4, Title: "Cut costs by 500"})
+		req, _ := http.NewRequest("GET", "/todos?q="+url.QueryEscape("50%"), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 1, len(response))
+		assert.Equal(t, 3, response[0].ID)
+		assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("Search Combined With Done", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Learn Rust", Done: true})
+		req, _ := http.NewRequest("GET", "/todos?q=learn&done=false", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 1, len(response))
+		assert.Equal(t, 1, response[0].ID)
+	})
+
+	t.Run("Filter By Overdue", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+		resetTodos(
+			Todo{ID: 3, Title: "Late", DueDate: &past},
+			Todo{ID: 4, Title: "Late but done", Done: true, DueDate: &past},
+			Todo{ID: 5, Title: "Not due yet", DueDate: &future},
+		)
+		req, _ := http.NewRequest("GET", "/todos?overdue=true", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, []int{3}, todoIDs(response))
+	})
+
+	t.Run("Invalid Overdue", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos?overdue=soon", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Filter By Created Range", func(t *testing.T) {
+		day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+		resetTodos(
+			Todo{ID: 3, Title: "April", CreatedAt: day(1).Add(-time.Hour)},
+			Todo{ID: 4, Title: "First", CreatedAt: day(1)},
+			Todo{ID: 5, Title: "Second", CreatedAt: day(2)},
+			Todo{ID: 6, Title: "Third", CreatedAt: day(3)},
+		)
+		for query, want := range map[string][]int{
+			"created_after=2024-05-01T00:00:00Z&created_before=2024-05-02T00:00:00Z": {4, 5},
+			"created_after=2024-05-02T00:00:00Z":                                     {1, 2, 5, 6},
+			"created_before=2024-05-01T00:00:00Z":                                    {3, 4},
+		} {
+			req, _ := http.NewRequest("GET", "/todos?"+query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			var response []Todo
+			json.Unmarshal(w.Body.Bytes(), &response)
+
+			assert.Equal(t, http.StatusOK, w.Code, query)
+			assert.Equal(t, want, todoIDs(response), query)
+		}
+	})
+
+	t.Run("Invalid Created Range", func(t *testing.T) {
+		resetTodos()
+		for _, query := range []string{"created_after=yesterday", "created_before=2024-05-01", "created_after=2024-05-02T00:00:00Z&created_before=2024-05-01T00:00:00Z"} {
+			req, _ := http.NewRequest("GET", "/todos?"+query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	t.Run("Invalid Done", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos?done=maybe", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "done must be true or false")
+	})
+
+	t.Run("Invalid Pagination", func(t *testing.T) {
+		resetTodos()
+		for _, query := range []string{"limit=-1", "limit=0", "limit=abc", "offset=-5"} {
+			req, _ := http.NewRequest("GET", "/todos?"+query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	t.Run("Done As String", func(t *testing.T) {
+		resetTodos()
+		config.DoneAsString = true
+		defer func() { config = defaultConfig() }()
+
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, "false", response[0]["done"])
+		assert.Equal(t, "Learn Go", response[0]["title"])
+	})
+
+	t.Run("Filter By Tag", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Pay rent", Tags: []string{"urgent", "home"}}, Todo{ID: 4, Title: "Mow lawn", Tags: []string{"home"}})
+		req, _ := http.NewRequest("GET", "/todos?tag=URGENT", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, []int{3}, todoIDs(response))
+		assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("Sort", func(t *testing.T) {
+		base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+		resetTodos(
+			Todo{ID: 3, Title: "apply for visa", Done: true, CreatedAt: base.Add(3 * time.Hour)},
+			Todo{ID: 4, Title: "Zip up", CreatedAt: base.Add(time.Hour)},
+		)
+		testDB.todos[0].CreatedAt = base.Add(2 * time.Hour)
+		testDB.todos[1].CreatedAt = base
+
+		tests := map[string][]int{
+			"/todos?sort=id":                     {1, 2, 3, 4},
+			"/todos?sort=-id":                    {4, 3, 2, 1},
+			"/todos?sort=title":                  {3, 1, 2, 4},
+			"/todos?sort=-created_at":            {3, 1, 4, 2},
+			"/todos?sort=-created_at&done=false": {1, 4, 2},
+			"/todos?sort=title&limit=2&offset=1": {1, 2},
+		}
+		for url, want := range tests {
+			req, _ := http.NewRequest("GET", url, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, url)
+
+			var response []Todo
+			json.Unmarshal(w.Body.Bytes(), &response)
+
+			assert.Equal(t, want, todoIDs(response), url)
+		}
+	})
+
+	t.Run("Unknown Sort Field", func(t *testing.T) {
+		resetTodos()
+		for _, query := range []string{"sort=priority", "sort=-", "sort=id%3B+DROP+TABLE+todos"} {
+			req, _ := http.NewRequest("GET", "/todos?"+query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	t.Run("Truncated Over Max Rows", func(t *testing.T) {
+		resetTodos()
+		config.ListMaxRows = 1
+		defer func() { config = defaultConfig() }()
+
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Warning"))
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 1, len(response))
+	})
+
+	t.Run("Truncated Over Max Page Limit By Default", func(t *testing.T) {
+		todos := make([]Todo, maxPageLimit)
+		for i := range todos {
+			todos[i] = Todo{ID: i + 3, Title: "Todo " + strconv.Itoa(i+3)}
+		}
+		resetTodos(todos...)
+
+		req, _ := http.NewRequest("GET", "/todos?limit=5000", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Warning"), strconv.Itoa(maxPageLimit))
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, maxPageLimit, len(response))
+	})
+
+	t.Run("Rejected Over Max Rows", func(t *testing.T) {
+		resetTodos()
+		config.ListMaxRows = 1
+		config.ListOverflow = listOverflowReject
+		defer func() { config = defaultConfig() }()
+
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Envelope", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs", Done: true}, Todo{ID: 4, Title: "Ship", Done: true})
+		req, _ := http.NewRequest("GET", "/todos?envelope=true&done=true&limit=1&offset=1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+		var response todoList
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, []int{4}, todoIDs(response.Data))
+		assert.Equal(t, 2, response.Total)
+		assert.Equal(t, 1, response.Limit)
+		assert.Equal(t, 1, response.Offset)
+	})
+
+	t.Run("Envelope Through Accept", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		req.Header.Set("Accept", todoListMediaType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, todoListMediaType, w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Values("Vary"), "Accept")
+
+		var response todoList
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, todoList{Data: response.Data, Total: 2, Limit: defaultPageLimit}, response)
+		assert.Equal(t, 2, len(response.Data))
+	})
+
+	t.Run("Invalid Envelope", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos?envelope=maybe", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/xml")
+
+		var response todoXMLList
+		assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, []int{1, 2}, todoIDs(response.Todos))
+		assert.Equal(t, "Learn Go", response.Todos[0].Title)
+	})
+
+	t.Run("JSON By Default", func(t *testing.T) {
+		resetTodos()
+		for _, accept := range []string{"", "application/json", "*/*", "text/html"} {
+			req, _ := http.NewRequest("GET", "/todos", nil)
+			req.Header.Set("Accept", accept)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, accept)
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json", accept)
+		}
+	})
+
+	t.Run("Not Modified Since", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		lastModified := w.Header().Get("Last-Modified")
+		assert.NotEmpty(t, lastModified)
+
+		req, _ = http.NewRequest("GET", "/todos", nil)
+		req.Header.Set("If-Modified-Since", lastModified)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("Modified Since By Delete", func(t *testing.T) {
+		resetTodos()
+		since := time.Now().UTC().Format(http.TimeFormat)
+		timeNow = func() time.Time { return time.Now().Add(time.Hour) }
+		defer func() { timeNow = time.Now }()
+		testDB.HardDeleteTodo(context.Background(), 1)
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		req.Header.Set("If-Modified-Since", since)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []int{2}, todoIDs(response))
+	})
+
+	t.Run("Cursor Pagination", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs"})
+		url := "/todos?limit=2&cursor="
+		var ids []int
+		for pages := 0; pages < 3; pages++ {
+			req, _ := http.NewRequest("GET", url, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+
+			var response todoPage
+			json.Unmarshal(w.Body.Bytes(), &response)
+			ids = append(ids, todoIDs(response.Todos)...)
+			if response.NextCursor == nil {
+				break
+			}
+			url = "/todos?limit=2&cursor=" + *response.NextCursor
+		}
+		assert.Equal(t, []int{1, 2, 3}, ids)
+	})
+
+	t.Run("Cursor Skips Todos Deleted Meanwhile", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs"})
+		req, _ := http.NewRequest("GET", "/todos?limit=1&cursor="+encodeCursor(1), nil)
+		testDB.HardDeleteTodo(context.Background(), 1)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response todoPage
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, []int{2}, todoIDs(response.Todos))
+		assert.Equal(t, encodeCursor(2), *response.NextCursor)
+	})
+
+	t.Run("Cursor On Last Page", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos?cursor="+encodeCursor(2), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"todos": [], "next_cursor": null}`, w.Body.String())
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		resetTodos()
+		for _, query := range []string{"cursor=***", "cursor=" + encodeCursor(0), "cursor=&offset=1", "cursor=&sort=title"} {
+			req, _ := http.NewRequest("GET", "/todos?"+query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+}
+
+func TestGetTodoSuggestions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "learn Rust", Done: true})
+		req, _ := http.NewRequest("GET", "/todos/suggest?prefix=LEARN", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 2, len(response))
+		assert.Equal(t, "Learn Go", response[0]["title"])
+		assert.NotContains(t, response[0], "done")
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Learn Rust"})
+		req, _ := http.NewRequest("GET", "/todos/suggest?prefix=learn&limit=1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []todoSuggestion
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, []todoSuggestion{{ID: 1, Title: "Learn Go"}}, response)
+	})
+
+	t.Run("No Matches", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/suggest?prefix=buy", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]", w.Body.String())
+	})
+
+	t.Run("Empty Prefix", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/suggest?prefix=", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid Limit", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/suggest?prefix=learn&limit=0", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestGetTodoStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Done", Done: true}, Todo{ID: 4, Title: "Gone", Done: true, DeletedAt: &time.Time{}})
+		req, _ := http.NewRequest("GET", "/todos/stats", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response todoStats
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, todoStats{Total: 3, Done: 1, Pending: 2}, response)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		testDB = NewDatabase()
+		db = testDB
+		req, _ := http.NewRequest("GET", "/todos/stats", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"total": 0, "done": 0, "pending": 0}`, w.Body.String())
+	})
+}
+
+func TestGetTodosDueSoon(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		soon := time.Now().Add(2 * time.Hour)
+		sooner := time.Now().Add(time.Hour)
+		later := time.Now().Add(48 * time.Hour)
+		resetTodos(
+			Todo{ID: 3, Title: "Overdue", DueDate: &past},
+			Todo{ID: 4, Title: "Soon", DueDate: &soon},
+			Todo{ID: 5, Title: "Sooner", DueDate: &sooner},
+			Todo{ID: 6, Title: "Soon but done", Done: true, DueDate: &soon},
+			Todo{ID: 7, Title: "Later", DueDate: &later},
+		)
+		req, _ := http.NewRequest("GET", "/todos/due-soon?within=3h", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, []int{5, 4}, todoIDs(response))
+	})
+
+	t.Run("Defaults To A Day", func(t *testing.T) {
+		soon := time.Now().Add(23 * time.Hour)
+		later := time.Now().Add(25 * time.Hour)
+		resetTodos(Todo{ID: 3, Title: "Soon", DueDate: &soon}, Todo{ID: 4, Title: "Later", DueDate: &later})
+		req, _ := http.NewRequest("GET", "/todos/due-soon", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, []int{3}, todoIDs(response))
+	})
+
+	t.Run("Invalid Within", func(t *testing.T) {
+		resetTodos()
+		for _, within := range []string{"tomorrow", "-1h", "0s", ""} {
+			req, _ := http.NewRequest("GET", "/todos/due-soon?within="+within, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, within)
+		}
+	})
+}
+
+func TestGetStaleTodos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	daysAgo := func(days int) time.Time { return time.Now().AddDate(0, 0, -days) }
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos(
+			Todo{ID: 3, Title: "Old", CreatedAt: daysAgo(40)},
+			Todo{ID: 4, Title: "Older", CreatedAt: daysAgo(50)},
+			Todo{ID: 5, Title: "Old but done", Done: true, CreatedAt: daysAgo(60)},
+			Todo{ID: 6, Title: "Recent", CreatedAt: daysAgo(5)},
+		)
+		w := get("/todos/stale?days=30")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, []int{4, 3}, todoIDs(response))
+
+		json.Unmarshal(get("/todos/stale?days=45").Body.Bytes(), &response)
+		assert.Equal(t, []int{4}, todoIDs(response))
+	})
+
+	t.Run("None Stale", func(t *testing.T) {
+		resetTodos()
+		w := get("/todos/stale")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]", w.Body.String())
+	})
+
+	t.Run("Days Capped", func(t *testing.T) {
+		config.StaleMaxDays = 10
+		defer func() { config = defaultConfig() }()
+		resetTodos(Todo{ID: 3, Title: "Old", CreatedAt: daysAgo(20)})
+		w := get("/todos/stale?days=1000")
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, []int{3}, todoIDs(response))
+	})
+
+	t.Run("Invalid Days", func(t *testing.T) {
+		resetTodos()
+		for _, days := range []string{"month", "0", "-3", ""} {
+			assert.Equal(t, http.StatusBadRequest, get("/todos/stale?days="+days).Code, days)
+		}
+	})
+}
+
+func TestGetTodo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/2", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, todoETag(testDB.todos[1]), w.Header().Get("ETag"))
+
+		var response Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, "Set up CI/CD", response.Title)
+	})
+
+	t.Run("Include Is Overdue", func(t *testing.T) {
+		resetTodos()
+		past := time.Now().Add(-time.Hour)
+		testDB.todos[1].DueDate = &past
+		get := func(path string) map[string]any {
+			req, _ := http.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			var body map[string]any
+			json.Unmarshal(w.Body.Bytes(), &body)
+			return body
+		}
+
+		assert.NotContains(t, get("/todos/2"), "is_overdue")
+		assert.Equal(t, true, get("/todos/2?include=is_overdue")["is_overdue"])
+		assert.Equal(t, false, get("/todos/1?include=is_overdue")["is_overdue"])
+		assert.Equal(t, true, get("/v2/todos/2?include=is_overdue")["is_overdue"])
+		assert.Nil(t, testDB.todos[1].IsOverdue)
+	})
+
+	t.Run("Include Unknown Field", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/2?include=is_overdue,secrets", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		resetTodos()
+		testDB.todos[1].Tags = []string{"ops", "ci"}
+		req, _ := http.NewRequest("GET", "/todos/2", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "<todo><id>2</id><title>Set up CI/CD</title><done>false</done>")
+		assert.Contains(t, w.Body.String(), "<tags><tag>ops</tag><tag>ci</tag></tags>")
+
+		var response Todo
+		assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, []string{"ops", "ci"}, response.Tags)
+	})
+
+	t.Run("Not Modified", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/1", nil)
+		req.Header.Set("If-None-Match", todoETag(testDB.todos[0]))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, todoETag(testDB.todos[0]), w.Header().Get("ETag"))
+	})
+
+	t.Run("Modified Since ETag", func(t *testing.T) {
+		resetTodos()
+		etag := todoETag(testDB.todos[0])
+		testDB.todos[0].Title = "Learn Go properly"
+		req, _ := http.NewRequest("GET", "/todos/1", nil)
+		req.Header.Set("If-None-Match", etag)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/999", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestPostTodo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "New Todo", "done": false}`
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, "New Todo", response.Title)
+		assert.Equal(t, 3, response.ID)
+		assert.False(t, response.CreatedAt.IsZero())
+		assert.Equal(t, response.CreatedAt, response.UpdatedAt)
+	})
+
+	t.Run("With Due Date", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "New Todo", "due_date": "2030-05-01T17:00:00Z"}`
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, time.Date(2030, 5, 1, 17, 0, 0, 0, time.UTC), response.DueDate.UTC())
+	})
+
+	t.Run("Without Due Date", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "New Todo"}`
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.NotContains(t, w.Body.String(), "due_date")
+	})
+
+	t.Run("With Tags", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "New Todo", "tags": ["Urgent", " home ", "urgent", ""]}`
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, []string{"urgent", "home"}, response.Tags)
+		assert.Equal(t, []string{"urgent", "home"}, testDB.todos[2].Tags)
+	})
+
+	t.Run("Malformed Due Date", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "New Todo", "due_date": "next friday"}`
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "New Todo", "done": }` // Invalid JSON
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("Title Validation", func(t *testing.T) {
+		tests := map[string]struct {
+			payload string
+			message string
+		}{
+			"Missing":  {`{"done": true}`, "title is required"},
+			"Empty":    {`{"title": ""}`, "title is required"},
+			"Blank":    {`{"title": "   "}`, "title must not be blank"},
+			"Too Long": {`{"title": "` + strings.Repeat("x", 256) + `"}`, "title must be at most 255 characters"},
+		}
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				resetTodos()
+				req, _ := http.NewRequest("POST", "/todos", strings.NewReader(tt.payload))
+				req.Header.Set("Content-Type", "application/json")
+
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Contains(t, w.Body.String(), tt.message)
+				assert.Equal(t, 2, len(testDB.todos))
+			})
+		}
+	})
+
+	t.Run("Title At Max Length", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "` + strings.Repeat("x", 255) + `"}`
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("Unique Titles", func(t *testing.T) {
+		resetTodos()
+		config.UniqueTitles = true
+		defer func() { config = defaultConfig() }()
+
+		tests := []struct {
+			payload string
+			want    int
+		}{
+			{`{"title": "LEARN GO"}`, http.StatusConflict},
+			{`{"title": "Learn Rust"}`, http.StatusCreated},
+			{`{"title": "learn rust "}`, http.StatusConflict},
+		}
+		for _, tt := range tests {
+			req, _ := http.NewRequest("POST", "/todos", strings.NewReader(tt.payload))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code, tt.payload)
+		}
+		assert.Equal(t, 3, len(testDB.todos))
+
+		req, _ := http.NewRequest("POST", "/todos/batch", strings.NewReader(`[{"title": "Walk dog"}, {"title": "walk DOG"}]`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, 3, len(testDB.todos))
+	})
+
+	t.Run("Duplicate Titles Allowed By Default", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(`{"title": "Learn Go"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+}
+
+func TestPostTodosBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos()
+		payload := `[{"title": "First"}, {"title": "Second", "done": true}]`
+		req, _ := http.NewRequest("POST", "/todos/batch", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, []int{3, 4}, todoIDs(response))
+		assert.Equal(t, "Second", response[1].Title)
+		assert.Equal(t, 4, len(testDB.todos))
+	})
+
+	t.Run("Empty Batch", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos/batch", strings.NewReader(`[]`))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Too Large", func(t *testing.T) {
+		resetTodos()
+		payload := "[" + strings.Repeat(`{"title": "x"},`, maxBatchSize) + `{"title": "x"}]`
+		req, _ := http.NewRequest("POST", "/todos/batch", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+
+	t.Run("Blank Title Rejects Whole Batch", func(t *testing.T) {
+		resetTodos()
+		payload := `[{"title": "First"}, {"title": " "}]`
+		req, _ := http.NewRequest("POST", "/todos/batch", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "title must not be blank")
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+
+	t.Run("Invalid Item Rejects Whole Batch", func(t *testing.T) {
+		resetTodos()
+		payload := `[{"title": "First"}, {"title": "Second", "due_date": "someday"}]`
+		req, _ := http.NewRequest("POST", "/todos/batch", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+}
+
+// racingDatabase is a database where another client changes the todo right
+// after each GetTodoByID, as if its write landed between a read and a write
+type racingDatabase struct {
+	DatabaseInterface
+}
+
+func (d racingDatabase) GetTodoByID(ctx context.Context, id int) (*Todo, error) {
+	todo, err := d.DatabaseInterface.GetTodoByID(ctx, id)
+	if err == nil {
+		title := "Changed elsewhere"
+		d.DatabaseInterface.PatchTodo(ctx, id, TodoPatch{Title: &title})
+	}
+	return todo, err
+}
+
+func TestPutTodo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos()
+		timeNow = func() time.Time { return time.Now().Add(time.Hour) }
+		defer func() { timeNow = time.Now }()
+
+		payload := `{"title": "Updated Todo", "done": true}`
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, "Updated Todo", response.Title)
+		assert.Equal(t, true, response.Done)
+		assert.True(t, response.UpdatedAt.After(response.CreatedAt))
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "Updated Todo", "done": }` // Invalid JSON
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "Updated Todo", "done": true}`
+		req, _ := http.NewRequest("PUT", "/todos/999", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Matching If-Match", func(t *testing.T) {
+		resetTodos()
+		timeNow = func() time.Time { return time.Now().Add(time.Hour) }
+		defer func() { timeNow = time.Now }()
+
+		payload := `{"title": "Updated Todo", "done": true}`
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", todoETag(testDB.todos[0]))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Updated Todo", testDB.todos[0].Title)
+		assert.Equal(t, todoETag(testDB.todos[0]), w.Header().Get("ETag"))
+	})
+
+	t.Run("Stale If-Match", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "Updated Todo", "done": true}`
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", `"stale"`)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		assert.Equal(t, "Learn Go", testDB.todos[0].Title)
+	})
+
+	t.Run("If-Match Racing Another Write", func(t *testing.T) {
+		for _, mode := range []string{putModeReplace, putModeMerge} {
+			resetTodos()
+			config.PutMode = mode
+			db = racingDatabase{testDB}
+			payload := `{"title": "Updated Todo", "done": true}`
+			req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", todoETag(testDB.todos[0]))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusConflict, w.Code, mode)
+			assert.Equal(t, "Changed elsewhere", testDB.todos[0].Title, mode)
+		}
+		config = defaultConfig()
+	})
+
+	t.Run("Stale Version In Merge Mode", func(t *testing.T) {
+		resetTodos()
+		config.PutMode = putModeMerge
+		defer func() { config = defaultConfig() }()
+
+		testDB.todos[0].Version = 3
+		payload := `{"title": "Updated Todo", "version": 2}`
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "Learn Go", testDB.todos[0].Title)
+
+		payload = `{"title": "Updated Todo", "version": 3}`
+		req, _ = http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 4, testDB.todos[0].Version)
+	})
+
+	t.Run("Expected Version", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "Updated Todo", "done": true, "version": 1}`
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 2, response.Version)
+	})
+
+	t.Run("Stale Version", func(t *testing.T) {
+		resetTodos()
+		testDB.todos[0].Version = 3
+		payload := `{"title": "Updated Todo", "done": true, "version": 2}`
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "Learn Go", testDB.todos[0].Title)
+	})
+
+	t.Run("Unique Titles", func(t *testing.T) {
+		resetTodos()
+		config.UniqueTitles = true
+		defer func() { config = defaultConfig() }()
+
+		tests := []struct {
+			payload string
+			want    int
+		}{
+			{`{"title": "set up ci/cd", "done": false}`, http.StatusConflict},
+			{`{"title": "LEARN GO", "done": true}`, http.StatusOK},
+		}
+		for _, tt := range tests {
+			req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(tt.payload))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code, tt.payload)
+		}
+		assert.Equal(t, "LEARN GO", testDB.todos[0].Title)
+	})
+
+	t.Run("Missing Field In Replace Mode", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "Updated Todo"}`
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "done is required")
+		assert.Equal(t, "Learn Go", testDB.todos[0].Title)
+	})
+
+	t.Run("Title Too Long In Replace Mode", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "` + strings.Repeat("x", 256) + `", "done": true}`
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "title must be at most 255 characters")
+		assert.Equal(t, "Learn Go", testDB.todos[0].Title)
+	})
+
+	t.Run("Blank Title In Merge Mode", func(t *testing.T) {
+		resetTodos()
+		config.PutMode = putModeMerge
+		defer func() { config = defaultConfig() }()
+
+		payload := `{"title": "  "}`
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "title must not be blank")
+		assert.Equal(t, "Learn Go", testDB.todos[0].Title)
+	})
+
+	t.Run("Replace Mode Clears Omitted Due Date", func(t *testing.T) {
+		due := time.Now()
+		resetTodos()
+		testDB.todos[0].DueDate = &due
+		payload := `{"title": "Updated Todo", "done": false}`
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, testDB.todos[0].DueDate)
+	})
+
+	t.Run("Merge Mode Keeps Omitted Fields", func(t *testing.T) {
+		due := time.Now()
+		resetTodos()
+		config.PutMode = putModeMerge
+		defer func() { config = defaultConfig() }()
+
+		testDB.todos[0].Done = true
+		testDB.todos[0].DueDate = &due
+		payload := `{"title": "Updated Todo"}`
+		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, "Updated Todo", response.Title)
+		assert.Equal(t, true, response.Done)
+		assert.NotNil(t, response.DueDate)
+	})
+}
+
+func TestPatchTodo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Toggle Done", func(t *testing.T) {
+		resetTodos()
+		payload := `{"done": true}`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, "Learn Go", response.Title)
+		assert.Equal(t, true, response.Done)
+	})
+
+	t.Run("Null Due Date Clears It", func(t *testing.T) {
+		due := time.Now()
+		resetTodos()
+		testDB.todos[0].DueDate = &due
+		payload := `{"due_date": null}`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, testDB.todos[0].DueDate)
+	})
+
+	t.Run("Absent Due Date Is Kept", func(t *testing.T) {
+		due := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+		resetTodos()
+		testDB.todos[0].DueDate = &due
+		payload := `{"done": true}`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, due, *testDB.todos[0].DueDate)
+
+		payload = `{"due_date": "2024-05-06T09:00:00Z"}`
+		req, _ = http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC), *testDB.todos[0].DueDate)
+	})
+
+	t.Run("Invalid Due Date", func(t *testing.T) {
+		resetTodos()
+		payload := `{"due_date": "tomorrow"}`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Nil(t, testDB.todos[0].DueDate)
+	})
+
+	t.Run("Absent Title Is Kept", func(t *testing.T) {
+		resetTodos()
+		payload := `{"done": true}`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Learn Go", testDB.todos[0].Title)
+	})
+
+	t.Run("Empty Title Is Rejected", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": ""}`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, errorBody(w).Fields, "title")
+		assert.Equal(t, "Learn Go", testDB.todos[0].Title)
+	})
+
+	t.Run("Populated Title Is Set", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "Learn Rust"}`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Learn Rust", testDB.todos[0].Title)
+		assert.False(t, testDB.todos[0].Done)
+	})
+
+	t.Run("Stale Version", func(t *testing.T) {
+		resetTodos()
+		testDB.todos[0].Version = 3
+		payload := `{"done": true, "version": 2}`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.False(t, testDB.todos[0].Done)
+	})
+
+	t.Run("Replace Tags", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Pay rent", Tags: []string{"urgent"}})
+		payload := `{"tags": ["Home", "home"]}`
+		req, _ := http.NewRequest("PATCH", "/todos/3", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"home"}, testDB.todos[2].Tags)
+	})
+
+	t.Run("Completing A Recurring Todo Schedules The Next", func(t *testing.T) {
+		due := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+		resetTodos(Todo{ID: 3, Title: "Take out bins", DueDate: &due, Recurrence: recurrenceWeekly})
+		payload := `{"done": true}`
+		req, _ := http.NewRequest("PATCH", "/todos/3", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 4, len(testDB.todos))
+		next := testDB.todos[3]
+		assert.Equal(t, "Take out bins", next.Title)
+		assert.False(t, next.Done)
+		assert.Equal(t, recurrenceWeekly, next.Recurrence)
+		assert.Equal(t, due.AddDate(0, 0, 7), *next.DueDate)
+
+		// Completing it again does not schedule a second occurrence
+		req, _ = http.NewRequest("PATCH", "/todos/3", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, 4, len(testDB.todos))
+	})
+
+	t.Run("Unique Titles", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Take out bins", Recurrence: recurrenceDaily})
+		config.UniqueTitles = true
+		defer func() { config = defaultConfig() }()
+
+		tests := []struct {
+			id      string
+			payload string
+			want    int
+		}{
+			{"1", `{"title": "Set up CI/CD"}`, http.StatusConflict},
+			{"1", `{"title": "learn go "}`, http.StatusOK},
+			// The next occurrence would share the title
+			{"3", `{"done": true}`, http.StatusConflict},
+		}
+		for _, tt := range tests {
+			req, _ := http.NewRequest("PATCH", "/todos/"+tt.id, strings.NewReader(tt.payload))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code, tt.payload)
+		}
+		assert.Equal(t, 3, len(testDB.todos))
+		assert.False(t, testDB.todos[2].Done)
+	})
+
+	t.Run("Stop Recurring", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Take out bins", Recurrence: recurrenceDaily})
+		payload := `{"recurrence": "none", "done": true}`
+		req, _ := http.NewRequest("PATCH", "/todos/3", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "", testDB.todos[2].Recurrence)
+		assert.Equal(t, 3, len(testDB.todos))
+	})
+
+	t.Run("Invalid Recurrence", func(t *testing.T) {
+		resetTodos()
+		payload := `{"recurrence": "yearly"}`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, map[string]string{"recurrence": "must be one of none, daily, weekly, monthly"}, errorBody(w).Fields)
+	})
+
+	t.Run("Set To Zero Value", func(t *testing.T) {
+		resetTodos()
+		testDB.todos[0].Done = true
+		payload := `{"done": false}`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, false, testDB.todos[0].Done)
+	})
+
+	t.Run("No Updatable Fields", func(t *testing.T) {
+		resetTodos()
+		payload := `{}`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		resetTodos()
+		payload := `{"done": }`
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		resetTodos()
+		payload := `{"done": true}`
+		req, _ := http.NewRequest("PATCH", "/todos/999", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestUpdateTodosWhere(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs", Done: false})
+		testDB.todos[1].Done = true
+		payload := `{"filter": {"done": false}, "set": {"title": "Pending", "done": true}}`
+		req, _ := http.NewRequest("POST", "/todos/update-where", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]int
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 2, response["updated"])
+		assert.Equal(t, "Pending", testDB.todos[0].Title)
+		assert.Equal(t, "Set up CI/CD", testDB.todos[1].Title)
+		assert.Equal(t, "Pending", testDB.todos[2].Title)
+		assert.Equal(t, true, testDB.todos[2].Done)
+	})
+
+	t.Run("Unique Titles", func(t *testing.T) {
+		resetTodos()
+		config.UniqueTitles = true
+		defer func() { config = defaultConfig() }()
+		payload := `{"filter": {"done": false}, "set": {"title": "Pending"}}`
+		req, _ := http.NewRequest("POST", "/todos/update-where", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "Learn Go", testDB.todos[0].Title)
+	})
+
+	t.Run("Empty Filter Rejected", func(t *testing.T) {
+		resetTodos()
+		payload := `{"filter": {}, "set": {"done": true}}`
+		req, _ := http.NewRequest("POST", "/todos/update-where", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, false, testDB.todos[0].Done)
+	})
+
+	t.Run("Empty Filter With All", func(t *testing.T) {
+		resetTodos()
+		payload := `{"filter": {}, "set": {"done": true}, "all": true}`
+		req, _ := http.NewRequest("POST", "/todos/update-where", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"updated":2}`, w.Body.String())
+	})
+
+	t.Run("Empty Set", func(t *testing.T) {
+		resetTodos()
+		payload := `{"filter": {"done": false}, "set": {}}`
+		req, _ := http.NewRequest("POST", "/todos/update-where", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		resetTodos()
+		payload := `{"filter": }`
+		req, _ := http.NewRequest("POST", "/todos/update-where", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestSetAllDone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	post := func(path string) (int, map[string]int) {
+		req, _ := http.NewRequest("POST", path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response map[string]int
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	t.Run("Complete All", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs", Done: true}, Todo{ID: 4, Title: "Someone else's", UserID: "bob"})
+		code, response := post("/todos/complete-all")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2, response["updated"])
+		assert.True(t, testDB.todos[0].Done)
+		assert.True(t, testDB.todos[1].Done)
+		assert.Equal(t, 1, testDB.todos[2].Version)
+		assert.False(t, testDB.todos[3].Done)
+	})
+
+	t.Run("Uncomplete All", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs", Done: true})
+		code, response := post("/todos/uncomplete-all")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 1, response["updated"])
+		assert.False(t, testDB.todos[2].Done)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		testDB = NewDatabase()
+		db = testDB
+		code, response := post("/todos/complete-all")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 0, response["updated"])
+	})
+}
+
+func TestReorderTodos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	listIDs := func() []int {
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return todoIDs(response)
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs"})
+		req, _ := http.NewRequest("POST", "/todos/reorder", strings.NewReader(`{"ids": [3, 1, 2]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"reordered": 3}`, w.Body.String())
+		assert.Equal(t, []int{3, 1, 2}, listIDs())
+	})
+
+	t.Run("Subset Keeps Others In Place", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs"})
+		req, _ := http.NewRequest("POST", "/todos/reorder", strings.NewReader(`{"ids": [3, 1]}`))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, []int{3, 2, 1}, listIDs())
+
+		req, _ = http.NewRequest("POST", "/todos", strings.NewReader(`{"title": "Last"}`))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, []int{3, 2, 1, 4}, listIDs())
+	})
+
+	t.Run("Unknown ID", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos/reorder", strings.NewReader(`{"ids": [2, 999]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []int{1, 2}, listIDs())
+	})
+
+	t.Run("Duplicate ID", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos/reorder", strings.NewReader(`{"ids": [2, 1, 2]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "ids must not repeat, but 2 does", errorBody(w).Message)
+	})
+
+	t.Run("Missing IDs", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos/reorder", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Empty IDs", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos/reorder", strings.NewReader(`{"ids": []}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "ids must not be empty", errorBody(w).Message)
+	})
+}
+
+func TestClaimTodos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs"})
+		testDB.todos[0].Done = true
+		payload := `{"worker_id": "worker-1", "count": 5, "lease_seconds": 60}`
+		req, _ := http.NewRequest("POST", "/todos/claim", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 2, len(response))
+		assert.Equal(t, 2, response[0].ID)
+		assert.Equal(t, "worker-1", response[0].ClaimedBy)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), *response[0].LeaseExpiresAt, 5*time.Second)
+		// Claiming is an update, so conditional requests see it
+		assert.Equal(t, 2, response[0].Version)
+		assert.Equal(t, 1, testDB.todos[0].Version)
+	})
+
+	t.Run("Claimed Todos Are Skipped", func(t *testing.T) {
+		resetTodos()
+		payload := `{"worker_id": "worker-1"}`
+		for _, want := range []string{`"id":1`, `"id":2`, `[]`} {
+			req, _ := http.NewRequest("POST", "/todos/claim", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), want)
+		}
+	})
+
+	t.Run("Expired Lease Is Claimable", func(t *testing.T) {
+		expired := time.Now().Add(-time.Minute)
+		resetTodos()
+		testDB.todos[0].ClaimedBy = "worker-1"
+		testDB.todos[0].LeaseExpiresAt = &expired
+		payload := `{"worker_id": "worker-2"}`
+		req, _ := http.NewRequest("POST", "/todos/claim", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, 1, len(response))
+		assert.Equal(t, 1, response[0].ID)
+		assert.Equal(t, "worker-2", response[0].ClaimedBy)
+	})
+
+	t.Run("Missing Worker", func(t *testing.T) {
+		resetTodos()
+		payload := `{"count": 1}`
+		req, _ := http.NewRequest("POST", "/todos/claim", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid Count", func(t *testing.T) {
+		resetTodos()
+		payload := `{"worker_id": "worker-1", "count": -1}`
+		req, _ := http.NewRequest("POST", "/todos/claim", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 }
 
-func TestGetTodos(t *testing.T) {
+func TestDeleteTodo(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := SetupRouter()
 
 	t.Run("Success", func(t *testing.T) {
 		resetTodos()
-		req, _ := http.NewRequest("GET", "/todos", nil)
+		req, _ := http.NewRequest("DELETE", "/todos/1", nil)
+
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, "Todo deleted", response["message"])
+		assert.Equal(t, []int{1, 2}, todoIDs(testDB.todos))
+		assert.Equal(t, []int{2}, liveTodoIDs(testDB.todos))
+	})
+
+	t.Run("Hidden From List Unless Requested", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("DELETE", "/todos/1", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		req, _ = http.NewRequest("GET", "/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
 		var response []Todo
 		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, []int{2}, todoIDs(response))
 
-		assert.Equal(t, 2, len(response))
-		assert.Equal(t, "Learn Go", response[0].Title)
+		req, _ = http.NewRequest("GET", "/todos?include_deleted=true", nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, []int{1, 2}, todoIDs(response))
+		assert.NotNil(t, response[0].DeletedAt)
+		assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("Already Deleted", func(t *testing.T) {
+		resetTodos()
+		testDB.DeleteTodo(context.Background(), 1)
+		req, _ := http.NewRequest("DELETE", "/todos/1", nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Hard Delete", func(t *testing.T) {
+		resetTodos()
+		testDB.DeleteTodo(context.Background(), 1)
+		req, _ := http.NewRequest("DELETE", "/todos/1?hard=true", nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []int{2}, todoIDs(testDB.todos))
+	})
+
+	t.Run("Invalid Hard Flag", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("DELETE", "/todos/1?hard=maybe", nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []int{1, 2}, liveTodoIDs(testDB.todos))
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("DELETE", "/todos/999", nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
 }
 
-func TestPostTodo(t *testing.T) {
+func TestRestoreTodo(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := SetupRouter()
 
 	t.Run("Success", func(t *testing.T) {
 		resetTodos()
-		payload := `{"title": "New Todo", "done": false}`
-		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(payload))
-		req.Header.Set("Content-Type", "application/json")
+		testDB.DeleteTodo(context.Background(), 1)
+		req, _ := http.NewRequest("POST", "/todos/1/restore", nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []int{1, 2}, liveTodoIDs(testDB.todos))
+	})
+
+	t.Run("Title Taken Meanwhile", func(t *testing.T) {
+		resetTodos()
+		config.UniqueTitles = true
+		defer func() { config = defaultConfig() }()
+		ctx := context.Background()
+		testDB.DeleteTodo(ctx, 1)
+		testDB.CreateTodo(ctx, &Todo{Title: "LEARN GO"})
+		req, _ := http.NewRequest("POST", "/todos/1/restore", nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, []int{2, 3}, liveTodoIDs(testDB.todos))
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos/999/restore", nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestDuplicateTodo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Success", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Water plants", Done: true, Tags: []string{"home"}})
+		req, _ := http.NewRequest("POST", "/todos/3/duplicate", nil)
 
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
@@ -56,93 +2096,205 @@ func TestPostTodo(t *testing.T) {
 		var response Todo
 		json.Unmarshal(w.Body.Bytes(), &response)
 
-		assert.Equal(t, "New Todo", response.Title)
-		assert.Equal(t, 3, response.ID)
+		assert.Equal(t, 4, response.ID)
+		assert.Equal(t, "Water plants (copy)", response.Title)
+		assert.False(t, response.Done)
+		assert.Equal(t, []string{"home"}, response.Tags)
+		assert.Equal(t, 1, response.Version)
+		assert.Equal(t, "Water plants", testDB.todos[2].Title)
 	})
 
-	t.Run("Invalid JSON", func(t *testing.T) {
-		resetTodos()
-		payload := `{"title": "New Todo", "done": }` // Invalid JSON
-		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(payload))
-		req.Header.Set("Content-Type", "application/json")
+	t.Run("Long Title Is Shortened", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: strings.Repeat("a", maxTitleLength)})
+		req, _ := http.NewRequest("POST", "/todos/3/duplicate", nil)
 
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var response Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, maxTitleLength, len(response.Title))
+		assert.True(t, strings.HasSuffix(response.Title, duplicateSuffix))
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		resetTodos()
+		testDB.DeleteTodo(context.Background(), 1)
+		for _, id := range []string{"1", "999"} {
+			req, _ := http.NewRequest("POST", "/todos/"+id+"/duplicate", nil)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		}
 	})
 }
 
-func TestPutTodo(t *testing.T) {
+func TestDeleteTodos(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := SetupRouter()
 
 	t.Run("Success", func(t *testing.T) {
-		resetTodos()
-		payload := `{"title": "Updated Todo", "done": true}`
-		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		resetTodos(Todo{ID: 3, Title: "Write docs"})
+		payload := `{"ids": [1, 3, 999, 3]}`
+		req, _ := http.NewRequest("DELETE", "/todos", strings.NewReader(payload))
 		req.Header.Set("Content-Type", "application/json")
 
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"deleted":2}`, w.Body.String())
+		assert.Equal(t, []int{2}, liveTodoIDs(testDB.todos))
+	})
 
-		var response Todo
-		json.Unmarshal(w.Body.Bytes(), &response)
+	t.Run("Parent With Subtasks", func(t *testing.T) {
+		parent := 1
+		resetTodos(Todo{ID: 3, Title: "Install Go", ParentID: &parent})
+		del := func(payload string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("DELETE", "/todos", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
 
-		assert.Equal(t, "Updated Todo", response.Title)
-		assert.Equal(t, true, response.Done)
+		w := del(`{"ids": [1, 2]}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, []int{1, 2, 3}, liveTodoIDs(testDB.todos))
+
+		w = del(`{"ids": [1, 3]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"deleted":2}`, w.Body.String())
+		assert.Equal(t, []int{2}, liveTodoIDs(testDB.todos))
 	})
 
-	t.Run("Invalid JSON", func(t *testing.T) {
+	t.Run("Only Missing IDs", func(t *testing.T) {
 		resetTodos()
-		payload := `{"title": "Updated Todo", "done": }` // Invalid JSON
-		req, _ := http.NewRequest("PUT", "/todos/1", strings.NewReader(payload))
+		payload := `{"ids": [999]}`
+		req, _ := http.NewRequest("DELETE", "/todos", strings.NewReader(payload))
 		req.Header.Set("Content-Type", "application/json")
 
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"deleted":0}`, w.Body.String())
 	})
 
-	t.Run("Not Found", func(t *testing.T) {
+	t.Run("Empty IDs", func(t *testing.T) {
 		resetTodos()
-		payload := `{"title": "Updated Todo", "done": true}`
-		req, _ := http.NewRequest("PUT", "/todos/999", strings.NewReader(payload))
+		payload := `{"ids": []}`
+		req, _ := http.NewRequest("DELETE", "/todos", strings.NewReader(payload))
 		req.Header.Set("Content-Type", "application/json")
 
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+
+	t.Run("Concurrent Overlapping Deletes", func(t *testing.T) {
+		var extra []Todo
+		for id := 3; id <= 20; id++ {
+			extra = append(extra, Todo{ID: id, Title: "Todo " + strconv.Itoa(id)})
+		}
+		resetTodos(extra...)
+		payloads := []string{`{"ids": [1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12]}`, `{"ids": [7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18]}`}
+
+		var wg sync.WaitGroup
+		counts := make([]int, len(payloads))
+		for i, payload := range payloads {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req, _ := http.NewRequest("DELETE", "/todos", strings.NewReader(payload))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				var body struct {
+					Deleted int `json:"deleted"`
+				}
+				if assert.Equal(t, http.StatusOK, w.Code) && assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body)) {
+					counts[i] = body.Deleted
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 18, counts[0]+counts[1])
+		assert.Equal(t, []int{19, 20}, liveTodoIDs(testDB.todos))
 	})
 }
 
-func TestDeleteTodo(t *testing.T) {
+func TestClearCompleted(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := SetupRouter()
 
-	t.Run("Success", func(t *testing.T) {
-		resetTodos()
-		req, _ := http.NewRequest("DELETE", "/todos/1", nil)
-
+	t.Run("Mixed Done And Pending", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs", Done: true}, Todo{ID: 4, Title: "Ship it"})
+		testDB.todos[0].Done = true
+		req, _ := http.NewRequest("POST", "/todos/clear-completed", nil)
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"deleted":2}`, w.Body.String())
+		assert.Equal(t, []int{2, 4}, liveTodoIDs(testDB.todos))
+	})
 
-		var response map[string]string
-		json.Unmarshal(w.Body.Bytes(), &response)
+	t.Run("Done Parent With Pending Subtasks", func(t *testing.T) {
+		parent := 1
+		resetTodos(Todo{ID: 3, Title: "Install Go", ParentID: &parent})
+		testDB.todos[0].Done = true
+		req, _ := http.NewRequest("POST", "/todos/clear-completed", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
 
-		assert.Equal(t, "Todo deleted", response["message"])
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, []int{1, 2, 3}, liveTodoIDs(testDB.todos))
 	})
 
-	t.Run("Not Found", func(t *testing.T) {
+	t.Run("Nothing To Clear", func(t *testing.T) {
 		resetTodos()
-		req, _ := http.NewRequest("DELETE", "/todos/999", nil)
+		req, _ := http.NewRequest("POST", "/todos/clear-completed", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"deleted":0}`, w.Body.String())
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+}
+
+func TestOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Collection", func(t *testing.T) {
+		req, _ := http.NewRequest("OPTIONS", "/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "DELETE, GET, OPTIONS, POST", w.Header().Get("Allow"))
+	})
+
+	t.Run("Item", func(t *testing.T) {
+		req, _ := http.NewRequest("OPTIONS", "/todos/1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "DELETE, GET, OPTIONS, PATCH, PUT", w.Header().Get("Allow"))
+	})
 
+	t.Run("Unknown Path", func(t *testing.T) {
+		req, _ := http.NewRequest("OPTIONS", "/unknown", nil)
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 
@@ -150,6 +2302,58 @@ func TestDeleteTodo(t *testing.T) {
 	})
 }
 
+func TestBasePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	get := func(r *gin.Engine, path string) int {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Routes Under Prefix", func(t *testing.T) {
+		config.BasePath = "/api/v1"
+		r := SetupRouter()
+		config = defaultConfig()
+		resetTodos()
+
+		assert.Equal(t, http.StatusOK, get(r, "/api/v1/todos"))
+		assert.Equal(t, http.StatusOK, get(r, "/api/v1/todos/1"))
+		assert.Equal(t, http.StatusOK, get(r, "/api/v1/compat/todos"))
+		assert.Equal(t, http.StatusNotFound, get(r, "/todos"))
+		assert.Equal(t, http.StatusOK, get(r, "/healthz"))
+		assert.Equal(t, http.StatusOK, get(r, "/metrics"))
+		assert.Equal(t, http.StatusNotFound, get(r, "/api/v1/healthz"))
+	})
+
+	t.Run("Ops Under Prefix", func(t *testing.T) {
+		config.BasePath = "/api/v1"
+		config.OpsUnderBasePath = true
+		r := SetupRouter()
+		config = defaultConfig()
+		resetTodos()
+
+		assert.Equal(t, http.StatusOK, get(r, "/api/v1/healthz"))
+		assert.Equal(t, http.StatusOK, get(r, "/api/v1/metrics"))
+		assert.Equal(t, http.StatusNotFound, get(r, "/healthz"))
+	})
+
+	t.Run("Import Keeps Its Body Limit", func(t *testing.T) {
+		config.BasePath = "/api/v1"
+		config.MaxBodyBytes = 16
+		r := SetupRouter()
+		config = defaultConfig()
+		resetTodos()
+
+		req, _ := http.NewRequest("POST", "/api/v1/todos/import", strings.NewReader("title\nBuy milk\nWalk the dog\n"))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
 func TestToInt(t *testing.T) {
 	t.Run("Valid Integer", func(t *testing.T) {
 		result := toInt("123")
@@ -171,3 +2375,151 @@ func TestToInt(t *testing.T) {
 		assert.Equal(t, 0, result)
 	})
 }
+
+func TestAPIVersions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("V1 Mirrors Unversioned", func(t *testing.T) {
+		resetTodos()
+		unversioned := get("/todos")
+		v1 := get("/v1/todos")
+
+		assert.Equal(t, http.StatusOK, v1.Code)
+		assert.JSONEq(t, unversioned.Body.String(), v1.Body.String())
+		assert.Equal(t, http.StatusOK, get("/v1/compat/todos").Code)
+	})
+
+	t.Run("V2 Lists In Envelope", func(t *testing.T) {
+		resetTodos()
+		w := get("/v2/todos?limit=1")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var list todoList
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		assert.Equal(t, 2, list.Total)
+		assert.Equal(t, 1, list.Limit)
+		assert.Len(t, list.Data, 1)
+
+		var raw struct {
+			Data []map[string]any `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+		assert.Contains(t, raw.Data[0], "created_at")
+		assert.Contains(t, raw.Data[0], "updated_at")
+	})
+
+	t.Run("V1 Leaves Out Unset Timestamps", func(t *testing.T) {
+		resetTodos()
+		var todo map[string]any
+		assert.NoError(t, json.Unmarshal(get("/v1/todos/1").Body.Bytes(), &todo))
+		assert.NotContains(t, todo, "due_date")
+		assert.NotContains(t, todo, "lease_expires_at")
+		assert.NotContains(t, todo, "deleted_at")
+
+		var todos []map[string]any
+		assert.NoError(t, json.Unmarshal(get("/todos").Body.Bytes(), &todos))
+		assert.NotContains(t, todos[0], "due_date")
+	})
+
+	t.Run("V2 Sends Unset Timestamps As Null", func(t *testing.T) {
+		resetTodos()
+		var v1, v2 map[string]any
+		assert.NoError(t, json.Unmarshal(get("/v1/todos/1").Body.Bytes(), &v1))
+		assert.NoError(t, json.Unmarshal(get("/v2/todos/1").Body.Bytes(), &v2))
+
+		for _, field := range []string{"due_date", "lease_expires_at", "deleted_at"} {
+			value, ok := v2[field]
+			assert.True(t, ok, field)
+			assert.Nil(t, value, field)
+			delete(v2, field)
+		}
+		assert.Equal(t, v1, v2)
+	})
+
+	t.Run("V1 And V2 Agree On Set Timestamps", func(t *testing.T) {
+		due := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		resetTodos()
+		testDB.todos[0].DueDate = &due
+		var v1, v2 map[string]any
+		assert.NoError(t, json.Unmarshal(get("/v1/todos/1").Body.Bytes(), &v1))
+		assert.NoError(t, json.Unmarshal(get("/v2/todos/1").Body.Bytes(), &v2))
+
+		assert.Equal(t, "2030-01-01T00:00:00Z", v1["due_date"])
+		assert.Equal(t, v1["due_date"], v2["due_date"])
+	})
+
+	t.Run("V2 Shares Todo Routes", func(t *testing.T) {
+		resetTodos()
+		assert.Equal(t, http.StatusOK, get("/v2/todos/1").Code)
+		assert.Equal(t, http.StatusOK, get("/v2/compat/todos").Code)
+	})
+
+	t.Run("V2 Has Admin Routes", func(t *testing.T) {
+		config.AdminToken = "secret"
+		defer func() { config = defaultConfig() }()
+		r := SetupRouter()
+		resetTodos()
+
+		req, _ := http.NewRequest("GET", "/v2/admin/title-stats", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Versioned Import Keeps Its Body Limit", func(t *testing.T) {
+		config.MaxBodyBytes = 16
+		r := SetupRouter()
+		config = defaultConfig()
+		resetTodos()
+
+		req, _ := http.NewRequest("POST", "/v2/todos/import", strings.NewReader("title\nBuy milk\nWalk the dog\n"))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestXMLResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	for _, tc := range []struct {
+		method, path, body, root string
+	}{
+		{"POST", "/todos", `{"title": "Write docs"}`, "<todo>"},
+		{"POST", "/todos/batch", `[{"title": "Write docs"}]`, "<todos>"},
+		{"PUT", "/todos/1", `{"title": "Learn Go", "done": true}`, "<todo>"},
+		{"PATCH", "/todos/1", `{"done": true}`, "<todo>"},
+		{"POST", "/todos/1/duplicate", "", "<todo>"},
+		{"GET", "/todos/stats", "", "<todo_stats>"},
+		{"GET", "/todos/due-soon", "", "<todos>"},
+		{"GET", "/todos/stale", "", "<todos>"},
+		{"GET", "/todos/1/subtasks", "", "<todos>"},
+		{"POST", "/categories", `{"name": "Work"}`, "<category>"},
+		{"GET", "/categories", "", "<categories>"},
+		{"GET", "/todos/suggest?prefix=learn", "", "<suggestions><suggestion><id>1</id><title>Learn Go</title></suggestion></suggestions>"},
+	} {
+		resetCategories("Home")
+		req, _ := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Less(t, w.Code, 300, tc.path)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/xml", tc.path)
+		assert.True(t, strings.HasPrefix(w.Body.String(), tc.root), tc.path)
+		assert.Contains(t, w.Header().Values("Vary"), "Accept", tc.path)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsPath is where Prometheus scrapes; requests to it are not counted
+const metricsPath = "/metrics"
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by method, route and status.",
+	}, []string{"method", "path", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path"})
+
+	todosTotal = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "todos_total",
+		Help: "Number of stored todos across all users as of the last list request.",
+	})
+)
+
+// metricsMiddleware records request counts and latencies labeled by route
+// template, so /todos/1 and /todos/2 share a series. opsBase is the prefix
+// metricsPath is served under.
+func metricsMiddleware(opsBase string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if routeOf(c, opsBase) == metricsPath {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = "unmatched"
+		}
+		httpRequestsTotal.WithLabelValues(c.Request.Method, path, strconv.Itoa(c.Writer.Status())).Inc()
+		httpRequestDuration.WithLabelValues(c.Request.Method, path).Observe(time.Since(start).Seconds())
+	}
+}
+
+// getMetrics handles GET /metrics
+var getMetrics = gin.WrapH(promhttp.Handler())
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	t.Run("Counts Requests By Route", func(t *testing.T) {
+		resetTodos()
+		counter := httpRequestsTotal.WithLabelValues("DELETE", "/todos/:id", "404")
+		before := testutil.ToFloat64(counter)
+
+		for _, path := range []string{"/todos/998", "/todos/999"} {
+			req, _ := http.NewRequest("DELETE", path, nil)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		assert.Equal(t, before+2, testutil.ToFloat64(counter))
+	})
+
+	t.Run("Todos Gauge Updated On List", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs", Done: true})
+		req, _ := http.NewRequest("GET", "/todos?done=true", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, 3.0, testutil.ToFloat64(todosTotal))
+	})
+
+	t.Run("Todos Gauge Counts Every User", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Write docs", UserID: "alice"})
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		req.Header.Set(userIDHeader, "bob")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, 3.0, testutil.ToFloat64(todosTotal))
+	})
+
+	t.Run("Metrics Endpoint", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "http_requests_total")
+		assert.Contains(t, w.Body.String(), "http_request_duration_seconds")
+		assert.Contains(t, w.Body.String(), "todos_total")
+	})
+
+	t.Run("Metrics Endpoint Not Counted", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		req, _ = http.NewRequest("GET", "/metrics", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.NotContains(t, w.Body.String(), `path="/metrics"`)
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDHeader carries the request ID in and out of the API
+const requestIDHeader = "X-Request-ID"
+
+// requestIDKey is the gin context key holding the request ID
+const requestIDKey = "request_id"
+
+// requestLogger logs one structured line per request. It reuses the caller's
+// X-Request-ID or generates one, and echoes it on the response.
+func requestLogger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(requestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("request_id", id),
+		)
+	}
+}
+
+// Headers used in CORS responses
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, Authorization, If-Match, If-None-Match, If-Modified-Since, X-Request-ID, X-User-ID"
+	corsExposeHeaders = "X-Total-Count, X-Request-ID, ETag, Last-Modified"
+	corsMaxAge        = "600"
+)
+
+// corsMiddleware adds CORS headers for the allowed origins and answers their
+// preflight requests. Requests from other origins get no CORS headers, so
+// browsers block them, and their preflights are refused with 403.
+func corsMiddleware(allowedOrigins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[origin] = true
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if !allowed[origin] {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Access-Control-Expose-Headers", corsExposeHeaders)
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+			c.Header("Access-Control-Allow-Headers", corsAllowHeaders)
+			c.Header("Access-Control-Max-Age", corsMaxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// apiVersions are the prefixes that registerV1Routes and registerV2Routes are
+// mounted at, besides the unversioned routes
+var apiVersions = []string{"/v1", "/v2"}
+
+// routeOf returns the route template the request matched, less base and any
+// API version, e.g. "/todos/:id" for /api/v2/todos/1 when base is /api. It is
+// empty when no route matched.
+func routeOf(c *gin.Context, base string) string {
+	route := strings.TrimPrefix(c.FullPath(), base)
+	for _, version := range apiVersions {
+		if rest, ok := strings.CutPrefix(route, version+"/"); ok {
+			return "/" + rest
+		}
+	}
+	return route
+}
+
+// routeBodyLimits overrides config.MaxBodyBytes for routes that accept larger bodies
+var routeBodyLimits = map[string]int64{
+	"/todos/import": maxImportBytes,
+}
+
+// bodyLimitMiddleware caps request bodies at limit bytes, or at the route's
+// entry in routeBodyLimits. A body declaring a larger Content-Length is
+// refused with 413 straight away; one that only turns out larger fails with
+// 413 once the handler reads past the cap. A limit of 0 leaves bodies uncapped.
+// base is the prefix the API routes are served under.
+func bodyLimitMiddleware(limit int64, base string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bodyLimit := limit
+		if routeLimit, ok := routeBodyLimits[routeOf(c, base)]; ok {
+			bodyLimit = routeLimit
+		}
+		if bodyLimit <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > bodyLimit {
+			respondBodyTooLarge(c, bodyLimit)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, bodyLimit)
+		c.Next()
+	}
+}
+
+// streamingRoutes hold their connection open for as long as the client
+// listens, so they are exempt from the request timeout
+var streamingRoutes = map[string]bool{
+	"/todos/events": true,
+	"/ws":           true,
+}
+
+// requestTimeoutMiddleware puts a deadline of timeout on the request context.
+// Store calls fail once it passes, and a request not answered by then gets a
+// 503. Routes in streamingRoutes, under base, are exempt, and a timeout of 0
+// disables it. It must run before gzipMiddleware, which holds back small
+// bodies until the handler returns.
+func requestTimeoutMiddleware(timeout time.Duration, base string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 || streamingRoutes[routeOf(c, base)] {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			respondTimeout(c)
+		}
+	}
+}
+
+// newRequestID returns a random 16-byte hex identifier
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(buf *bytes.Buffer, level slog.Level) *gin.Engine {
+		r := gin.New()
+		r.Use(requestLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: level}))))
+		r.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+		r.GET("/missing", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+		return r
+	}
+
+	t.Run("Logs JSON Line", func(t *testing.T) {
+		var buf bytes.Buffer
+		r := newRouter(&buf, slog.LevelInfo)
+		req, _ := http.NewRequest("GET", "/ok", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var entry map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, "INFO", entry["level"])
+		assert.Equal(t, "GET", entry["method"])
+		assert.Equal(t, "/ok", entry["path"])
+		assert.Equal(t, 200.0, entry["status"])
+		assert.Contains(t, entry, "latency")
+		assert.Contains(t, entry, "client_ip")
+		assert.Equal(t, w.Header().Get(requestIDHeader), entry["request_id"])
+		assert.Len(t, entry["request_id"], 32)
+	})
+
+	t.Run("Reuses Request ID", func(t *testing.T) {
+		var buf bytes.Buffer
+		r := newRouter(&buf, slog.LevelInfo)
+		req, _ := http.NewRequest("GET", "/ok", nil)
+		req.Header.Set(requestIDHeader, "abc-123")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, "abc-123", w.Header().Get(requestIDHeader))
+		assert.Contains(t, buf.String(), `"request_id":"abc-123"`)
+	})
+
+	t.Run("Client Errors Log At Warn", func(t *testing.T) {
+		var buf bytes.Buffer
+		r := newRouter(&buf, slog.LevelWarn)
+		req, _ := http.NewRequest("GET", "/ok", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Empty(t, buf.String())
+
+		req, _ = http.NewRequest("GET", "/missing", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Contains(t, buf.String(), `"level":"WARN"`)
+	})
+}
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.CORSOrigins = []string{"https://app.example.com"}
+	r := SetupRouter()
+	config = defaultConfig()
+
+	t.Run("Preflight From Allowed Origin", func(t *testing.T) {
+		req, _ := http.NewRequest("OPTIONS", "/todos/1", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "PUT")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "PUT")
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Content-Type")
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	})
+
+	t.Run("Simple Request From Allowed Origin", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "X-Total-Count")
+	})
+
+	t.Run("Preflight From Rejected Origin", func(t *testing.T) {
+		req, _ := http.NewRequest("OPTIONS", "/todos", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		req.Header.Set("Access-Control-Request-Method", "DELETE")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Simple Request From Rejected Origin", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Denied By Default", func(t *testing.T) {
+		r := SetupRouter()
+		req, _ := http.NewRequest("OPTIONS", "/todos", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Plain OPTIONS Still Lists Methods", func(t *testing.T) {
+		req, _ := http.NewRequest("OPTIONS", "/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Allow"))
+	})
+}
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.MaxBodyBytes = 64
+	r := SetupRouter()
+	config = defaultConfig()
+
+	t.Run("Oversized Body", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "` + strings.Repeat("a", 100) + `"}`
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Equal(t, "request_entity_too_large", errorBody(w).Code)
+		assert.Equal(t, 2, len(testDB.todos))
+	})
+
+	t.Run("Oversized Body Without Content-Length", func(t *testing.T) {
+		resetTodos()
+		payload := `{"title": "` + strings.Repeat("a", 100) + `"}`
+		req, _ := http.NewRequest("POST", "/todos", io.NopCloser(strings.NewReader(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("Body Within Limit", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(`{"title": "Small"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("Import Has Its Own Limit", func(t *testing.T) {
+		resetTodos()
+		body := "title\n" + strings.Repeat("Buy milk\n", 10)
+		req, _ := http.NewRequest("POST", "/todos/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+// slowDatabase is a database whose listing waits until the request gives up
+type slowDatabase struct {
+	DatabaseInterface
+}
+
+func (slowDatabase) GetTodosPage(ctx context.Context, filter TodoFilter, order TodoOrder, limit, offset int) ([]Todo, int, error) {
+	<-ctx.Done()
+	return nil, 0, ctx.Err()
+}
+
+func TestRequestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.RequestTimeout = 20 * time.Millisecond
+	r := SetupRouter()
+	config = defaultConfig()
+
+	t.Run("Slow Request", func(t *testing.T) {
+		resetTodos()
+		db = slowDatabase{db}
+		defer func() { db = testDB }()
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "service_unavailable", errorBody(w).Code)
+	})
+
+	t.Run("Fast Request", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Event Stream Is Exempt", func(t *testing.T) {
+		resetTodos()
+		srv := httptest.NewServer(r)
+		defer srv.Close()
+		resp, err := http.Get(srv.URL + "/todos/events")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		time.Sleep(50 * time.Millisecond)
+		events.publish("", todoEvent{Type: eventDeleted, ID: 1})
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "event:deleted\n", line)
+	})
+}
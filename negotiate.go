@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// todoXMLList wraps a bare list of todos for XML, which needs a single root element
+type todoXMLList struct {
+	XMLName xml.Name `xml:"todos"`
+	Todos   []Todo   `xml:"todo"`
+}
+
+// categoryXMLList wraps a list of categories for XML
+type categoryXMLList struct {
+	XMLName    xml.Name   `xml:"categories"`
+	Categories []Category `xml:"category"`
+}
+
+// suggestionXMLList wraps a list of todo suggestions for XML
+type suggestionXMLList struct {
+	XMLName     xml.Name         `xml:"suggestions"`
+	Suggestions []todoSuggestion `xml:"suggestion"`
+}
+
+// wantsXML reports whether the Accept header asks for XML rather than JSON.
+// Clients that accept neither get JSON.
+func wantsXML(c *gin.Context) bool {
+	switch c.NegotiateFormat(gin.MIMEJSON, gin.MIMEXML, gin.MIMEXML2) {
+	case gin.MIMEXML, gin.MIMEXML2:
+		return true
+	}
+	return false
+}
+
+// varyOnAccept adds Accept to the Vary header unless it is already there
+func varyOnAccept(c *gin.Context) {
+	for _, value := range c.Writer.Header().Values("Vary") {
+		if strings.EqualFold(value, "Accept") {
+			return
+		}
+	}
+	c.Writer.Header().Add("Vary", "Accept")
+}
+
+// includeIsOverdue is the include query value that adds is_overdue to todos
+const includeIsOverdue = "is_overdue"
+
+// includeIsOverdueKey is the gin context key set when the request asks for is_overdue
+const includeIsOverdueKey = "include_is_overdue"
+
+// parseInclude reads the include query parameter, a comma-separated list of
+// derived fields to add to the todos in the response. It answers 400 for a
+// field it does not know.
+func parseInclude(c *gin.Context) {
+	if s, ok := c.GetQuery("include"); ok {
+		for _, field := range strings.Split(s, ",") {
+			if strings.TrimSpace(field) != includeIsOverdue {
+				respondError(c, http.StatusBadRequest, "include must be a comma-separated list of: "+includeIsOverdue)
+				return
+			}
+			c.Set(includeIsOverdueKey, true)
+		}
+	}
+	c.Next()
+}
+
+// withOverdue returns body with IsOverdue set, as of now, on every todo in it
+func withOverdue(body any, now time.Time) any {
+	mark := func(todos []Todo) {
+		for i := range todos {
+			overdue := todos[i].isOverdue(now)
+			todos[i].IsOverdue = &overdue
+		}
+	}
+	switch b := body.(type) {
+	case Todo:
+		todos := []Todo{b}
+		mark(todos)
+		return todos[0]
+	case *Todo:
+		todos := []Todo{*b}
+		mark(todos)
+		return &todos[0]
+	case []Todo:
+		mark(b)
+	case todoXMLList:
+		mark(b.Todos)
+	case todoPage:
+		mark(b.Todos)
+	case todoList:
+		mark(b.Data)
+	}
+	return body
+}
+
+// respondNegotiated writes body as XML if the client asks for it, and as JSON
+// otherwise, in the shape of the route's API version. body needs xml tags
+// naming its root element.
+func respondNegotiated(c *gin.Context, status int, body any) {
+	varyOnAccept(c)
+	if c.GetBool(includeIsOverdueKey) {
+		body = withOverdue(body, timeNow())
+	}
+	body = forAPIVersion(apiVersion(c), body)
+	if wantsXML(c) {
+		c.XML(status, body)
+		return
+	}
+	c.JSON(status, body)
+}
+
+// respondTodos writes a list of todos as a JSON array, or as a todos element
+// if the client asks for XML
+func respondTodos(c *gin.Context, status int, todos []Todo) {
+	if wantsXML(c) {
+		respondNegotiated(c, status, todoXMLList{Todos: todos})
+		return
+	}
+	respondNegotiated(c, status, todos)
+}
+
+// respondCategories writes a list of categories as a JSON array, or as a
+// categories element if the client asks for XML
+func respondCategories(c *gin.Context, status int, categories []Category) {
+	varyOnAccept(c)
+	if wantsXML(c) {
+		c.XML(status, categoryXMLList{Categories: categories})
+		return
+	}
+	c.JSON(status, categories)
+}
+
+// respondSuggestions writes a list of todo suggestions as a JSON array, or as
+// a suggestions element if the client asks for XML
+func respondSuggestions(c *gin.Context, status int, suggestions []todoSuggestion) {
+	varyOnAccept(c)
+	if wantsXML(c) {
+		c.XML(status, suggestionXMLList{Suggestions: suggestions})
+		return
+	}
+	c.JSON(status, suggestions)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// How often idle clients are swept from a rate limiter, and how long a client
+// must be idle to be swept. An idle client's bucket is full again, so
+// forgetting it changes nothing.
+const (
+	rateLimitSweepInterval = time.Minute
+	rateLimitIdleTimeout   = 3 * time.Minute
+)
+
+// ipRateLimiter keeps one token bucket per client IP
+type ipRateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	clients   map[string]*rateLimitedClient
+	lastSweep time.Time
+}
+
+// rateLimitedClient is the bucket of one client IP
+type rateLimitedClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newIPRateLimiter returns a limiter allowing each IP rps requests per second
+// with bursts of up to burst requests
+func newIPRateLimiter(rps float64, burst int) *ipRateLimiter {
+	return &ipRateLimiter{
+		limit:   rate.Limit(rps),
+		burst:   burst,
+		clients: map[string]*rateLimitedClient{},
+	}
+}
+
+// allow takes a token from ip's bucket at now. When the bucket is empty it
+// returns false and how long until a token is available.
+func (l *ipRateLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+	client, ok := l.clients[ip]
+	if !ok {
+		client = &rateLimitedClient{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[ip] = client
+	}
+	client.lastSeen = now
+
+	reservation := client.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// sweep forgets clients idle for longer than rateLimitIdleTimeout. Callers must hold mu.
+func (l *ipRateLimiter) sweep(now time.Time) {
+	for ip, client := range l.clients {
+		if now.Sub(client.lastSeen) > rateLimitIdleTimeout {
+			delete(l.clients, ip)
+		}
+	}
+	l.lastSweep = now
+}
+
+// rateLimitMiddleware answers 429 with a Retry-After header once a client IP
+// exceeds rps requests per second, allowing bursts of burst requests.
+// A burst below 1 defaults to rps rounded up. /healthz, under opsBase, is
+// never limited so probes keep working under load.
+func rateLimitMiddleware(rps float64, burst int, opsBase string) gin.HandlerFunc {
+	if burst < 1 {
+		burst = max(1, int(math.Ceil(rps)))
+	}
+	limiter := newIPRateLimiter(rps, burst)
+
+	return func(c *gin.Context) {
+		if routeOf(c, opsBase) == healthzPath {
+			c.Next()
+			return
+		}
+		if ok, retryAfter := limiter.allow(c.ClientIP(), time.Now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			respondError(c, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
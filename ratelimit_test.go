@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.RateLimitRPS = 0.5
+	config.RateLimitBurst = 2
+	r := SetupRouter()
+	config = defaultConfig()
+
+	get := func(path, ip string, headers ...string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.RemoteAddr = ip + ":1234"
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Burst Then Rejected", func(t *testing.T) {
+		resetTodos()
+		assert.Equal(t, http.StatusOK, get("/todos", "192.0.2.1").Code)
+		assert.Equal(t, http.StatusOK, get("/todos", "192.0.2.1").Code)
+
+		w := get("/todos", "192.0.2.1")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "2", w.Header().Get("Retry-After"))
+		assert.Equal(t, "too_many_requests", errorBody(w).Code)
+	})
+
+	t.Run("Clients Are Limited Separately", func(t *testing.T) {
+		resetTodos()
+		get("/todos", "192.0.2.2")
+		get("/todos", "192.0.2.2")
+		assert.Equal(t, http.StatusTooManyRequests, get("/todos", "192.0.2.2").Code)
+		assert.Equal(t, http.StatusOK, get("/todos", "192.0.2.3").Code)
+	})
+
+	t.Run("Forwarded For Is Not Trusted By Default", func(t *testing.T) {
+		resetTodos()
+		get("/todos", "192.0.2.5", "X-Forwarded-For", "198.51.100.1")
+		get("/todos", "192.0.2.5", "X-Forwarded-For", "198.51.100.2")
+		assert.Equal(t, http.StatusTooManyRequests, get("/todos", "192.0.2.5", "X-Forwarded-For", "198.51.100.3").Code)
+		assert.Equal(t, http.StatusTooManyRequests, get("/todos", "192.0.2.5", "X-Real-IP", "198.51.100.4").Code)
+	})
+
+	t.Run("Forwarded For Is Trusted From Trusted Proxies", func(t *testing.T) {
+		resetTodos()
+		config.RateLimitRPS = 0.5
+		config.RateLimitBurst = 2
+		config.TrustedProxies = []string{"10.0.0.0/8"}
+		r := SetupRouter()
+		config = defaultConfig()
+		get := func(client string) int {
+			req, _ := http.NewRequest("GET", "/todos", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set("X-Forwarded-For", client)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		get("198.51.100.5")
+		get("198.51.100.5")
+		assert.Equal(t, http.StatusTooManyRequests, get("198.51.100.5"))
+		assert.Equal(t, http.StatusOK, get("198.51.100.6"))
+	})
+
+	t.Run("Healthz Is Exempt", func(t *testing.T) {
+		resetTodos()
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusOK, get("/healthz", "192.0.2.4").Code)
+		}
+	})
+
+	t.Run("Disabled By Default", func(t *testing.T) {
+		resetTodos()
+		r := SetupRouter()
+		for i := 0; i < 5; i++ {
+			req, _ := http.NewRequest("GET", "/todos", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+	})
+}
+
+func TestIPRateLimiterSweep(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	limiter := newIPRateLimiter(1, 1)
+
+	limiter.allow("192.0.2.1", start)
+	limiter.allow("192.0.2.2", start.Add(2*time.Minute))
+	assert.Len(t, limiter.clients, 2)
+
+	limiter.allow("192.0.2.2", start.Add(rateLimitIdleTimeout+time.Minute))
+	assert.Len(t, limiter.clients, 1)
+	assert.Contains(t, limiter.clients, "192.0.2.2")
+}
@@ -0,0 +1,44 @@
+package main
+
+import "time"
+
+// Recurrence rules a todo can repeat on
+const (
+	recurrenceDaily   = "daily"
+	recurrenceWeekly  = "weekly"
+	recurrenceMonthly = "monthly"
+	// recurrenceNone stops a todo repeating in a PATCH
+	recurrenceNone = "none"
+)
+
+// nextDueDate returns the due date of the occurrence after one due at from
+func nextDueDate(rule string, from time.Time) time.Time {
+	switch rule {
+	case recurrenceDaily:
+		return from.AddDate(0, 0, 1)
+	case recurrenceWeekly:
+		return from.AddDate(0, 0, 7)
+	default:
+		return from.AddDate(0, 1, 0)
+	}
+}
+
+// nextOccurrence returns the pending todo that follows a completed recurring
+// one. Its due date is advanced from the completed one's, or from now when
+// that had none. The caller assigns its ID and timestamps.
+func nextOccurrence(todo Todo, now time.Time) Todo {
+	from := now
+	if todo.DueDate != nil {
+		from = *todo.DueDate
+	}
+	due := nextDueDate(todo.Recurrence, from)
+	return Todo{
+		Title:      todo.Title,
+		UserID:     todo.UserID,
+		DueDate:    &due,
+		CategoryID: todo.CategoryID,
+		Tags:       todo.Tags,
+		Recurrence: todo.Recurrence,
+		ParentID:   todo.ParentID,
+	}
+}
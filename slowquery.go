@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// slowQueryDatabase wraps a DatabaseInterface and logs a warning for every
+// call that takes longer than threshold, naming the method and its duration.
+// Faster calls are not logged.
+type slowQueryDatabase struct {
+	db        DatabaseInterface
+	threshold time.Duration
+	logger    *slog.Logger
+}
+
+// newSlowQueryDatabase returns db wrapped to log calls slower than threshold to logger
+func newSlowQueryDatabase(db DatabaseInterface, threshold time.Duration, logger *slog.Logger) *slowQueryDatabase {
+	return &slowQueryDatabase{db: db, threshold: threshold, logger: logger}
+}
+
+// observe logs method as a slow query if it has run longer than the
+// threshold since start. Defer it at the top of each method.
+func (d *slowQueryDatabase) observe(ctx context.Context, method string, start time.Time) {
+	elapsed := time.Since(start)
+	if elapsed <= d.threshold {
+		return
+	}
+	d.logger.LogAttrs(ctx, slog.LevelWarn, "slow query",
+		slog.String("method", method),
+		slog.Duration("duration", elapsed),
+	)
+}
+
+func (d *slowQueryDatabase) Ping(ctx context.Context) error {
+	defer d.observe(ctx, "Ping", time.Now())
+	return d.db.Ping(ctx)
+}
+
+func (d *slowQueryDatabase) GetTodos(ctx context.Context, filter TodoFilter, order TodoOrder, limit, offset int) ([]Todo, error) {
+	defer d.observe(ctx, "GetTodos", time.Now())
+	return d.db.GetTodos(ctx, filter, order, limit, offset)
+}
+
+func (d *slowQueryDatabase) GetTodosPage(ctx context.Context, filter TodoFilter, order TodoOrder, limit, offset int) ([]Todo, int, error) {
+	defer d.observe(ctx, "GetTodosPage", time.Now())
+	return d.db.GetTodosPage(ctx, filter, order, limit, offset)
+}
+
+func (d *slowQueryDatabase) GetTodoByID(ctx context.Context, id int) (*Todo, error) {
+	defer d.observe(ctx, "GetTodoByID", time.Now())
+	return d.db.GetTodoByID(ctx, id)
+}
+
+func (d *slowQueryDatabase) GetSubtasks(ctx context.Context, id int) ([]Todo, error) {
+	defer d.observe(ctx, "GetSubtasks", time.Now())
+	return d.db.GetSubtasks(ctx, id)
+}
+
+func (d *slowQueryDatabase) TotalTodos(ctx context.Context, filter TodoFilter) (int, error) {
+	defer d.observe(ctx, "TotalTodos", time.Now())
+	return d.db.TotalTodos(ctx, filter)
+}
+
+func (d *slowQueryDatabase) LastModified(ctx context.Context) (time.Time, error) {
+	defer d.observe(ctx, "LastModified", time.Now())
+	return d.db.LastModified(ctx)
+}
+
+func (d *slowQueryDatabase) CountTodos(ctx context.Context) (total, done int, err error) {
+	defer d.observe(ctx, "CountTodos", time.Now())
+	return d.db.CountTodos(ctx)
+}
+
+func (d *slowQueryDatabase) CountAllTodos(ctx context.Context) (int, error) {
+	defer d.observe(ctx, "CountAllTodos", time.Now())
+	return d.db.CountAllTodos(ctx)
+}
+
+func (d *slowQueryDatabase) SearchTodos(ctx context.Context, query string) ([]Todo, error) {
+	defer d.observe(ctx, "SearchTodos", time.Now())
+	return d.db.SearchTodos(ctx, query)
+}
+
+func (d *slowQueryDatabase) GetTodosByTag(ctx context.Context, tag string) ([]Todo, error) {
+	defer d.observe(ctx, "GetTodosByTag", time.Now())
+	return d.db.GetTodosByTag(ctx, tag)
+}
+
+func (d *slowQueryDatabase) GetTodosByTitlePrefix(ctx context.Context, prefix string, limit int) ([]Todo, error) {
+	defer d.observe(ctx, "GetTodosByTitlePrefix", time.Now())
+	return d.db.GetTodosByTitlePrefix(ctx, prefix, limit)
+}
+
+func (d *slowQueryDatabase) GetTodosDueWithin(ctx context.Context, dur time.Duration) ([]Todo, error) {
+	defer d.observe(ctx, "GetTodosDueWithin", time.Now())
+	return d.db.GetTodosDueWithin(ctx, dur)
+}
+
+func (d *slowQueryDatabase) GetStaleTodos(ctx context.Context, olderThan time.Time) ([]Todo, error) {
+	defer d.observe(ctx, "GetStaleTodos", time.Now())
+	return d.db.GetStaleTodos(ctx, olderThan)
+}
+
+func (d *slowQueryDatabase) TitleLengths(ctx context.Context) ([]int, error) {
+	defer d.observe(ctx, "TitleLengths", time.Now())
+	return d.db.TitleLengths(ctx)
+}
+
+func (d *slowQueryDatabase) CreateTodo(ctx context.Context, todo *Todo) error {
+	defer d.observe(ctx, "CreateTodo", time.Now())
+	return d.db.CreateTodo(ctx, todo)
+}
+
+func (d *slowQueryDatabase) CreateTodos(ctx context.Context, todos []Todo) ([]Todo, error) {
+	defer d.observe(ctx, "CreateTodos", time.Now())
+	return d.db.CreateTodos(ctx, todos)
+}
+
+func (d *slowQueryDatabase) DuplicateTodo(ctx context.Context, id int) (*Todo, error) {
+	defer d.observe(ctx, "DuplicateTodo", time.Now())
+	return d.db.DuplicateTodo(ctx, id)
+}
+
+func (d *slowQueryDatabase) CreateNextOccurrence(ctx context.Context, todo Todo) (*Todo, error) {
+	defer d.observe(ctx, "CreateNextOccurrence", time.Now())
+	return d.db.CreateNextOccurrence(ctx, todo)
+}
+
+func (d *slowQueryDatabase) UpdateTodo(ctx context.Context, id, version int, todo Todo) (*Todo, *Todo, error) {
+	defer d.observe(ctx, "UpdateTodo", time.Now())
+	return d.db.UpdateTodo(ctx, id, version, todo)
+}
+
+func (d *slowQueryDatabase) PatchTodo(ctx context.Context, id int, fields TodoPatch) (*Todo, *Todo, error) {
+	defer d.observe(ctx, "PatchTodo", time.Now())
+	return d.db.PatchTodo(ctx, id, fields)
+}
+
+func (d *slowQueryDatabase) UpdateTodosWhere(ctx context.Context, filter TodoFilter, set TodoPatch) (int, error) {
+	defer d.observe(ctx, "UpdateTodosWhere", time.Now())
+	return d.db.UpdateTodosWhere(ctx, filter, set)
+}
+
+func (d *slowQueryDatabase) SetAllDone(ctx context.Context, done bool) (int, error) {
+	defer d.observe(ctx, "SetAllDone", time.Now())
+	return d.db.SetAllDone(ctx, done)
+}
+
+func (d *slowQueryDatabase) ReorderTodos(ctx context.Context, ids []int) error {
+	defer d.observe(ctx, "ReorderTodos", time.Now())
+	return d.db.ReorderTodos(ctx, ids)
+}
+
+func (d *slowQueryDatabase) ClaimTodos(ctx context.Context, workerID string, n int, lease time.Duration) ([]Todo, error) {
+	defer d.observe(ctx, "ClaimTodos", time.Now())
+	return d.db.ClaimTodos(ctx, workerID, n, lease)
+}
+
+func (d *slowQueryDatabase) DeleteTodo(ctx context.Context, id int) error {
+	defer d.observe(ctx, "DeleteTodo", time.Now())
+	return d.db.DeleteTodo(ctx, id)
+}
+
+func (d *slowQueryDatabase) HardDeleteTodo(ctx context.Context, id int) error {
+	defer d.observe(ctx, "HardDeleteTodo", time.Now())
+	return d.db.HardDeleteTodo(ctx, id)
+}
+
+func (d *slowQueryDatabase) DeleteTodoCascade(ctx context.Context, id int, hard bool) (int, error) {
+	defer d.observe(ctx, "DeleteTodoCascade", time.Now())
+	return d.db.DeleteTodoCascade(ctx, id, hard)
+}
+
+func (d *slowQueryDatabase) RestoreTodo(ctx context.Context, id int) error {
+	defer d.observe(ctx, "RestoreTodo", time.Now())
+	return d.db.RestoreTodo(ctx, id)
+}
+
+func (d *slowQueryDatabase) DeleteTodos(ctx context.Context, ids []int) (int, error) {
+	defer d.observe(ctx, "DeleteTodos", time.Now())
+	return d.db.DeleteTodos(ctx, ids)
+}
+
+func (d *slowQueryDatabase) ClearCompleted(ctx context.Context) (int, error) {
+	defer d.observe(ctx, "ClearCompleted", time.Now())
+	return d.db.ClearCompleted(ctx)
+}
+
+func (d *slowQueryDatabase) CreateCategory(ctx context.Context, category *Category) error {
+	defer d.observe(ctx, "CreateCategory", time.Now())
+	return d.db.CreateCategory(ctx, category)
+}
+
+func (d *slowQueryDatabase) GetCategories(ctx context.Context) ([]Category, error) {
+	defer d.observe(ctx, "GetCategories", time.Now())
+	return d.db.GetCategories(ctx)
+}
+
+func (d *slowQueryDatabase) GetTodosByCategory(ctx context.Context, id int) ([]Todo, error) {
+	defer d.observe(ctx, "GetTodosByCategory", time.Now())
+	return d.db.GetTodosByCategory(ctx, id)
+}
+
+func (d *slowQueryDatabase) DeleteCategory(ctx context.Context, id int) error {
+	defer d.observe(ctx, "DeleteCategory", time.Now())
+	return d.db.DeleteCategory(ctx, id)
+}
+
+func (d *slowQueryDatabase) WithTx(ctx context.Context, fn func(tx TxInterface) error) error {
+	defer d.observe(ctx, "WithTx", time.Now())
+	return d.db.WithTx(ctx, fn)
+}
+
+func (d *slowQueryDatabase) Close() error {
+	defer d.observe(context.Background(), "Close", time.Now())
+	return d.db.Close()
+}
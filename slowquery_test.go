@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sleepyDatabase is a database whose lookups by ID take a while
+type sleepyDatabase struct {
+	DatabaseInterface
+}
+
+func (d sleepyDatabase) GetTodoByID(ctx context.Context, id int) (*Todo, error) {
+	time.Sleep(5 * time.Millisecond)
+	return d.DatabaseInterface.GetTodoByID(ctx, id)
+}
+
+func TestSlowQueryDatabase(t *testing.T) {
+	ctx := context.Background()
+	store := sleepyDatabase{NewDatabase(Todo{ID: 1, Title: "Seed"})}
+
+	t.Run("Logs Slow Query", func(t *testing.T) {
+		var buf bytes.Buffer
+		db := newSlowQueryDatabase(store, time.Millisecond, slog.New(slog.NewJSONHandler(&buf, nil)))
+		todo, err := db.GetTodoByID(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Seed", todo.Title)
+
+		var entry map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, "WARN", entry["level"])
+		assert.Equal(t, "slow query", entry["msg"])
+		assert.Equal(t, "GetTodoByID", entry["method"])
+		assert.Contains(t, entry, "duration")
+	})
+
+	t.Run("Fast Query Not Logged", func(t *testing.T) {
+		var buf bytes.Buffer
+		db := newSlowQueryDatabase(store, time.Hour, slog.New(slog.NewJSONHandler(&buf, nil)))
+		db.GetTodoByID(ctx, 1)
+		db.GetTodos(ctx, TodoFilter{}, TodoOrder{}, 10, 0)
+
+		assert.Empty(t, buf.String())
+	})
+}
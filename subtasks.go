@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getSubtasks handles GET /todos/:id/subtasks
+func getSubtasks(c *gin.Context) {
+	id := c.Param("id")
+
+	subtasks, err := db.GetSubtasks(c.Request.Context(), toInt(id))
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Todo not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respondTodos(c, http.StatusOK, subtasks)
+}
+
+// respondParentError writes a 400 response and returns true if err is an
+// invalid parent_id
+func respondParentError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, ErrParentNotFound):
+		respondError(c, http.StatusBadRequest, "parent_id does not name an existing todo")
+	case errors.Is(err, ErrParentCycle):
+		respondError(c, http.StatusBadRequest, "parent_id would make the todo a subtask of itself")
+	default:
+		return false
+	}
+	return true
+}
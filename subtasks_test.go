@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubtasks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+	one := 1
+
+	t.Run("Create And List", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Read the tour", ParentID: &one})
+		req, _ := http.NewRequest("POST", "/todos", strings.NewReader(`{"title": "Write a CLI", "parent_id": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		req, _ = http.NewRequest("GET", "/todos/1/subtasks", nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []Todo
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, []int{3, 4}, todoIDs(response))
+		assert.Equal(t, 1, *response[1].ParentID)
+	})
+
+	t.Run("No Subtasks", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/2/subtasks", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]", w.Body.String())
+	})
+
+	t.Run("Parent Not Found", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("GET", "/todos/999/subtasks", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		req, _ = http.NewRequest("POST", "/todos", strings.NewReader(`{"title": "Orphan", "parent_id": 999}`))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Read the tour", ParentID: &one})
+		req, _ := http.NewRequest("PATCH", "/todos/1", strings.NewReader(`{"parent_id": 3}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "parent_id would make the todo a subtask of itself", errorBody(w).Message)
+	})
+
+	t.Run("Delete Parent", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Read the tour", ParentID: &one})
+		req, _ := http.NewRequest("DELETE", "/todos/1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, []int{1, 2, 3}, liveTodoIDs(testDB.todos))
+
+		req, _ = http.NewRequest("DELETE", "/todos/1?cascade=true", nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []int{2}, liveTodoIDs(testDB.todos))
+	})
+
+	t.Run("Invalid Cascade", func(t *testing.T) {
+		resetTodos()
+		req, _ := http.NewRequest("DELETE", "/todos/1?cascade=maybe", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userIDHeader names the user making the request. It must be set by the
+// authenticating gateway in front of the API, which strips any value sent by
+// the client.
+const userIDHeader = "X-User-ID"
+
+// userIDKey is the context key holding the current user's ID
+type userIDKey struct{}
+
+// withUserID returns a copy of ctx carrying the user's ID
+func withUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// userIDFromContext returns the user ID carried by ctx. Requests without a
+// user share the anonymous owner "".
+func userIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
+// userMiddleware puts the request's user ID into the request context, where
+// the store uses it to scope every todo query to that user
+func userMiddleware(c *gin.Context) {
+	if userID := c.GetHeader(userIDHeader); userID != "" {
+		c.Request = c.Request.WithContext(withUserID(c.Request.Context(), userID))
+	}
+	c.Next()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserScoping(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	// do sends a request as user, with an optional JSON body
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if user != "" {
+			req.Header.Set(userIDHeader, user)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Create Sets Owner", func(t *testing.T) {
+		resetTodos()
+		w := do("POST", "/todos", "alice", `{"title": "Alice's todo"}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "alice", testDB.todos[2].UserID)
+	})
+
+	t.Run("List Shows Only Own Todos", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Alice's todo", UserID: "alice"}, Todo{ID: 4, Title: "Bob's todo", UserID: "bob"})
+
+		var response []Todo
+		json.Unmarshal(do("GET", "/todos", "alice", "").Body.Bytes(), &response)
+		assert.Equal(t, []int{3}, todoIDs(response))
+
+		json.Unmarshal(do("GET", "/todos", "", "").Body.Bytes(), &response)
+		assert.Equal(t, []int{1, 2}, todoIDs(response))
+	})
+
+	t.Run("Other Users' Todos Are Not Found", func(t *testing.T) {
+		resetTodos(Todo{ID: 3, Title: "Alice's todo", UserID: "alice"})
+
+		assert.Equal(t, http.StatusNotFound, do("GET", "/todos/3", "bob", "").Code)
+		assert.Equal(t, http.StatusNotFound, do("PUT", "/todos/3", "bob", `{"title": "Mine now", "done": true}`).Code)
+		assert.Equal(t, http.StatusNotFound, do("PATCH", "/todos/3", "bob", `{"done": true}`).Code)
+		assert.Equal(t, http.StatusNotFound, do("DELETE", "/todos/3", "bob", "").Code)
+		assert.Equal(t, `{"deleted":0}`, do("DELETE", "/todos", "bob", `{"ids": [3]}`).Body.String())
+
+		assert.Equal(t, "Alice's todo", testDB.todos[2].Title)
+		assert.Nil(t, testDB.todos[2].DeletedAt)
+		assert.Equal(t, http.StatusOK, do("GET", "/todos/3", "alice", "").Code)
+	})
+}
+
+func TestDatabaseUserScoping(t *testing.T) {
+	alice := withUserID(context.Background(), "alice")
+	bob := withUserID(context.Background(), "bob")
+	db := NewDatabase()
+	db.CreateTodos(alice, []Todo{{Title: "One", Done: true}, {Title: "Two"}})
+
+	total, _ := db.TotalTodos(bob, TodoFilter{})
+	assert.Equal(t, 0, total)
+	n, _ := db.ClearCompleted(bob)
+	assert.Equal(t, 0, n)
+	claimed, _ := db.ClaimTodos(bob, "worker", 10, 0)
+	assert.Empty(t, claimed)
+	matches, _ := db.SearchTodos(bob, "")
+	assert.Empty(t, matches)
+
+	total, _ = db.TotalTodos(alice, TodoFilter{})
+	assert.Equal(t, 2, total)
+	n, _ = db.ClearCompleted(alice)
+	assert.Equal(t, 1, n)
+}
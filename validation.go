@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Reject bodies with fields the request type does not have, so a typo
+	// such as "titel" fails instead of being silently ignored
+	binding.EnableDecoderDisallowUnknownFields = true
+
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	// Report fields by their JSON name so error messages match the request body
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	})
+}
+
+// fieldProblem describes why a field failed validation, e.g. "is required"
+func fieldProblem(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "max":
+		return "must be at most " + fe.Param() + " characters"
+	case "min":
+		if fe.Kind() == reflect.Slice && fe.Param() == "1" {
+			return "must not be empty"
+		}
+		if fe.Kind() == reflect.Slice {
+			return "must have at least " + fe.Param() + " entries"
+		}
+		return "must be at least " + fe.Param() + " characters"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	default:
+		return "is invalid"
+	}
+}
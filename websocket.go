@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Timing of the GET /ws connection. The server pings every wsPingPeriod and
+// drops a client that has not answered within wsPongWait.
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+// wsMaxMessageBytes caps what a client may send; the server only expects
+// control frames from it
+const wsMaxMessageBytes = 512
+
+// wsUpgrader turns GET /ws requests into WebSocket connections
+var wsUpgrader = websocket.Upgrader{CheckOrigin: wsCheckOrigin}
+
+// wsCheckOrigin accepts requests without an Origin header, from the request's
+// own host, or from one of config.CORSOrigins
+func wsCheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(config.CORSOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// serveWebSocket handles GET /ws, pushing the user's todo changes to the
+// client as JSON todoEvent frames. Messages from the client are read only to
+// answer pings and notice when it goes away. A client that falls more than
+// eventBufferSize events behind is disconnected.
+func serveWebSocket(c *gin.Context) {
+	// Subscribe first so that no event is missed between the handshake and
+	// the subscription
+	ch := events.subscribe(userIDFromContext(c.Request.Context()))
+	defer events.unsubscribe(ch)
+
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already answered with an error status
+		return
+	}
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		readWebSocket(conn)
+		close(closed)
+	}()
+	writeWebSocket(conn, ch, closed, apiVersion(c))
+}
+
+// readWebSocket discards client messages until the connection fails or no
+// pong arrives within wsPongWait
+func readWebSocket(conn *websocket.Conn) {
+	conn.SetReadLimit(wsMaxMessageBytes)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writeWebSocket sends events and pings until the subscription ends, the
+// reader stops, or a write fails, writing events in the shape of the given API
+// version. It is the only goroutine writing to conn.
+func writeWebSocket(conn *websocket.Conn, ch <-chan todoEvent, closed <-chan struct{}, version int) {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case event, ok := <-ch:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "event stream ended"))
+				return
+			}
+			if err := conn.WriteJSON(forAPIVersion(version, event)); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := httptest.NewServer(SetupRouter())
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	t.Run("Pushes Events", func(t *testing.T) {
+		resetTodos()
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		req, _ := http.NewRequest("DELETE", srv.URL+"/todos/1", nil)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		var event todoEvent
+		assert.NoError(t, conn.ReadJSON(&event))
+		assert.Equal(t, todoEvent{Type: eventDeleted, ID: 1}, event)
+	})
+
+	t.Run("Foreign Origin", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"https://evil.example"}})
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}